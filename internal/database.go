@@ -1,13 +1,18 @@
 package internal
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
 	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mattn/go-sqlite3"
@@ -17,13 +22,49 @@ import (
 func init() {
 	sql.Register("sqlite3_with_regex", &sqlite3.SQLiteDriver{
 		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
-			return conn.RegisterFunc("regexp", func(re, s string) (bool, error) {
-				return regexp.MatchString(re, s)
-			}, true)
+			if err := conn.RegisterFunc("regexp", matchSQLRegexp, true); err != nil {
+				return err
+			}
+			if err := conn.RegisterFunc("near_match", matchNearLines, true); err != nil {
+				return err
+			}
+			return conn.RegisterFunc("edit_distance", editDistance, true)
 		},
 	})
 }
 
+// Compiled patterns of the regexp SQL function. A query matches the same pattern against every row,
+// so patterns are compiled once. The cache is cleared when it reaches sqlRegexCacheSize patterns.
+var (
+	sqlRegexCache   = make(map[string]*regexp.Regexp)
+	sqlRegexCacheMu sync.Mutex
+)
+
+const sqlRegexCacheSize = 256
+
+// Implements the regexp SQL function.
+func matchSQLRegexp(pattern, s string) (bool, error) {
+	sqlRegexCacheMu.Lock()
+	re, ok := sqlRegexCache[pattern]
+	sqlRegexCacheMu.Unlock()
+
+	if !ok {
+		var err error
+		re, err = regexp.Compile(pattern)
+		if err != nil {
+			return false, err
+		}
+		sqlRegexCacheMu.Lock()
+		if len(sqlRegexCache) >= sqlRegexCacheSize {
+			clear(sqlRegexCache)
+		}
+		sqlRegexCache[pattern] = re
+		sqlRegexCacheMu.Unlock()
+	}
+
+	return re.MatchString(s), nil
+}
+
 // Creates the necessary tables and FTS5 virtual table
 func InitDB(path string, config DatabaseConfig) (*sql.DB, error) {
 	db, err := sql.Open("sqlite3_with_regex", path)
@@ -81,7 +122,13 @@ func InitDB(path string, config DatabaseConfig) (*sql.DB, error) {
 	CREATE TABLE IF NOT EXISTS transcript_lines (
 		rowid INTEGER PRIMARY KEY,
 		transcript_id TEXT NOT NULL,
+		lang TEXT NOT NULL DEFAULT '',
 		start_time TEXT,
+		start_ms INTEGER,
+		end_ms INTEGER,
+		cue_index INTEGER,
+		line_id INTEGER,
+		speaker TEXT NOT NULL DEFAULT '',
 		text TEXT,
 		clean_text TEXT,
 		FOREIGN KEY(transcript_id) REFERENCES transcripts(id) ON DELETE CASCADE
@@ -102,11 +149,41 @@ func InitDB(path string, config DatabaseConfig) (*sql.DB, error) {
 	CREATE TRIGGER IF NOT EXISTS tl_ad AFTER DELETE ON transcript_lines BEGIN
 		INSERT INTO transcript_search(transcript_search, rowid, clean_text) VALUES ('delete', old.rowid, old.clean_text);
 	END;
-	CREATE TRIGGER IF NOT EXISTS tl_au AFTER UPDATE ON transcript_lines BEGIN
+	CREATE TRIGGER IF NOT EXISTS tl_au AFTER UPDATE OF clean_text ON transcript_lines BEGIN
 		INSERT INTO transcript_search(transcript_search, rowid, clean_text) VALUES ('delete', old.rowid, old.clean_text);
 		INSERT INTO transcript_search(rowid, clean_text) VALUES (new.rowid, new.clean_text);
 	END;
 
+	-- Every upload of a track's content, and every correction of its lines, kept when the track is overwritten.
+	-- Revisions are numbered per transcript, across all of its tracks.
+	-- line_ids lists the line IDs of the revision in transcript order, separated by commas.
+	CREATE TABLE IF NOT EXISTS transcript_revisions (
+		transcript_id TEXT NOT NULL,
+		revision INTEGER NOT NULL,
+		lang TEXT NOT NULL DEFAULT '',
+		kind TEXT NOT NULL DEFAULT 'upload',
+		format TEXT NOT NULL,
+		content TEXT NOT NULL,
+		content_hash TEXT NOT NULL,
+		line_count INTEGER NOT NULL,
+		line_ids TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL,
+		PRIMARY KEY (transcript_id, revision)
+	);
+
+	-- Log of manual corrections made to transcript lines.
+	CREATE TABLE IF NOT EXISTS transcript_line_edits (
+		id INTEGER PRIMARY KEY,
+		transcript_id TEXT NOT NULL,
+		lang TEXT NOT NULL DEFAULT '',
+		revision INTEGER NOT NULL,
+		line_id TEXT NOT NULL,
+		old_text TEXT NOT NULL,
+		new_text TEXT NOT NULL,
+		editor TEXT NOT NULL,
+		edited_at TEXT NOT NULL
+	);
+
 	CREATE TABLE IF NOT EXISTS membership_keys (
 		key TEXT PRIMARY KEY,
 		channel TEXT NOT NULL,
@@ -116,6 +193,7 @@ func InitDB(path string, config DatabaseConfig) (*sql.DB, error) {
 	-- Add indexes to improve performance on large datasets
 	CREATE INDEX IF NOT EXISTS idx_transcript_lines_transcript_id ON transcript_lines(transcript_id);
 	CREATE INDEX IF NOT EXISTS idx_transcripts_date ON transcripts(date);
+	CREATE INDEX IF NOT EXISTS idx_transcript_line_edits_transcript_id ON transcript_line_edits(transcript_id);
 	`
 
 	_, err = db.Exec(schema) // Use Exec
@@ -124,6 +202,11 @@ func InitDB(path string, config DatabaseConfig) (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to create database schema: %w", err)
 	}
 
+	if err := migrateDB(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database schema: %w", err)
+	}
+
 	// Optimize the database - this runs ANALYZE and other maintenance.
 	// It's recommended to run this periodically or on startup for SQLite.
 	_, _ = db.Exec("PRAGMA optimize")
@@ -155,65 +238,878 @@ func InitDB(path string, config DatabaseConfig) (*sql.DB, error) {
 	return db, nil
 }
 
+// Brings a database created by an older version of the server up to the current schema.
+// Each step checks whether it has already been applied, so this is safe to run on every startup.
+func migrateDB(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// 0. tl_au. Older databases re-index a line on any update, which would rebuild the whole search index
+	// for backfills that don't touch the clean text.
+	if err := narrowSearchUpdateTrigger(tx); err != nil {
+		return err
+	}
+
+	// 1. transcript_lines.start_ms and end_ms. Older rows only have the "hh:mm:ss" start_time,
+	// so start_ms is derived from it and end_ms is taken from the start of the following line.
+	hasStartMs, err := columnExists(tx, "transcript_lines", "start_ms")
+	if err != nil {
+		return err
+	}
+	if !hasStartMs {
+		slog.Info("migrating transcript_lines to millisecond start and end times")
+		steps := []string{
+			"ALTER TABLE transcript_lines ADD COLUMN start_ms INTEGER",
+			"ALTER TABLE transcript_lines ADD COLUMN end_ms INTEGER",
+			`UPDATE transcript_lines SET start_ms = (
+				CAST(substr(start_time, 1, 2) AS INTEGER) * 3600 +
+				CAST(substr(start_time, 4, 2) AS INTEGER) * 60 +
+				CAST(substr(start_time, 7, 2) AS INTEGER)
+			) * 1000`,
+			`UPDATE transcript_lines SET end_ms = COALESCE(n.next_start_ms, transcript_lines.start_ms)
+			FROM (
+				SELECT rowid, LEAD(start_ms) OVER (PARTITION BY transcript_id ORDER BY start_ms, rowid) AS next_start_ms
+				FROM transcript_lines
+			) AS n
+			WHERE n.rowid = transcript_lines.rowid`,
+		}
+		for _, step := range steps {
+			if _, err := tx.Exec(step); err != nil {
+				return fmt.Errorf("failed to migrate transcript line times: %w", err)
+			}
+		}
+	}
+
+	// 2. transcript_lines.cue_index. Lines were always inserted in upload order, so rowid order
+	// within a transcript is the original cue order.
+	hasCueIndex, err := columnExists(tx, "transcript_lines", "cue_index")
+	if err != nil {
+		return err
+	}
+	if !hasCueIndex {
+		slog.Info("migrating transcript_lines to include cue index")
+		steps := []string{
+			"ALTER TABLE transcript_lines ADD COLUMN cue_index INTEGER",
+			`UPDATE transcript_lines SET cue_index = n.cue_index
+			FROM (
+				SELECT rowid, ROW_NUMBER() OVER (PARTITION BY transcript_id ORDER BY rowid) - 1 AS cue_index
+				FROM transcript_lines
+			) AS n
+			WHERE n.rowid = transcript_lines.rowid`,
+		}
+		for _, step := range steps {
+			if _, err := tx.Exec(step); err != nil {
+				return fmt.Errorf("failed to migrate transcript line cue index: %w", err)
+			}
+		}
+	}
+
+	// 3. transcript_lines.speaker. Older rows have no speaker until their transcript is uploaded again.
+	if err := addColumnIfMissing(tx, "transcript_lines", "speaker", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// 4. Track language. Everything stored before tracks existed belongs to the default track, ''.
+	// Content hashes are kept with each revision of a track, so the hash of the whole transcript is no longer used.
+	for _, table := range []string{"transcript_lines", "transcript_revisions", "transcript_line_edits"} {
+		if err := addColumnIfMissing(tx, table, "lang", "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+	if err := dropColumnIfExists(tx, "transcripts", "content_hash"); err != nil {
+		return err
+	}
+
+	// 5. Line IDs. Lines stored before IDs were kept are numbered by their position in the track,
+	// which is the ID they were returned with until now. Revisions stored before then have no line IDs,
+	// and are numbered by position when they are read.
+	hasLineID, err := columnExists(tx, "transcript_lines", "line_id")
+	if err != nil {
+		return err
+	}
+	if !hasLineID {
+		slog.Info("migrating transcript_lines to include line ids")
+		steps := []string{
+			"ALTER TABLE transcript_lines ADD COLUMN line_id INTEGER",
+			`UPDATE transcript_lines SET line_id = n.line_id
+			FROM (
+				SELECT rowid, ROW_NUMBER() OVER (PARTITION BY transcript_id, lang ORDER BY start_ms, cue_index) - 1 AS line_id
+				FROM transcript_lines
+			) AS n
+			WHERE n.rowid = transcript_lines.rowid`,
+		}
+		for _, step := range steps {
+			if _, err := tx.Exec(step); err != nil {
+				return fmt.Errorf("failed to migrate transcript line ids: %w", err)
+			}
+		}
+	}
+	if err := addColumnIfMissing(tx, "transcript_revisions", "kind", "TEXT NOT NULL DEFAULT 'upload'"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(tx, "transcript_revisions", "line_ids", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// 6. transcript_revisions. Transcripts stored before revisions were tracked get a first revision
+	// rebuilt from their lines, so their current text is kept when they are next overwritten.
+	if err := backfillRevisions(tx); err != nil {
+		return err
+	}
+
+	// 7. CJK clean text. Japanese and Chinese lines stored before CJK characters were indexed one by one
+	// are normalized again, so that words inside their sentences can be found. Finding them scans every line,
+	// so this only runs once, recorded in the user_version of the database.
+	var version int
+	if err := tx.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read database version: %w", err)
+	}
+	if version < cjkSegmentedVersion {
+		if err := resegmentCJKLines(tx); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", cjkSegmentedVersion)); err != nil {
+			return fmt.Errorf("failed to update database version: %w", err)
+		}
+	}
+
+	// 8. transcript_trigram. Substring index of the original text, used to narrow down regex searches.
+	if err := createTrigramIndex(tx); err != nil {
+		return err
+	}
+
+	// 9. transcript_words. Word index of the clean text without stemming, whose vocabulary is used by fuzzy search.
+	if err := createWordIndex(tx); err != nil {
+		return err
+	}
+
+	// Indexes on migrated columns can only be created once the columns exist.
+	_, err = tx.Exec("DROP INDEX IF EXISTS idx_transcript_lines_order")
+	if err != nil {
+		return fmt.Errorf("failed to drop transcript line order index: %w", err)
+	}
+	_, err = tx.Exec("CREATE INDEX IF NOT EXISTS idx_transcript_lines_track_order ON transcript_lines(transcript_id, lang, start_ms, cue_index)")
+	if err != nil {
+		return fmt.Errorf("failed to create transcript line order index: %w", err)
+	}
+	_, err = tx.Exec("CREATE INDEX IF NOT EXISTS idx_transcript_lines_speaker ON transcript_lines(transcript_id, speaker COLLATE NOCASE)")
+	if err != nil {
+		return fmt.Errorf("failed to create transcript line speaker index: %w", err)
+	}
+	_, err = tx.Exec("CREATE INDEX IF NOT EXISTS idx_transcript_lines_line_id ON transcript_lines(transcript_id, lang, line_id)")
+	if err != nil {
+		return fmt.Errorf("failed to create transcript line id index: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Recreates the tl_au trigger to fire only on updates of clean_text, if it fires on every update.
+func narrowSearchUpdateTrigger(tx *sql.Tx) error {
+	var definition string
+	err := tx.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'trigger' AND name = 'tl_au'").Scan(&definition)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read tl_au trigger: %w", err)
+	}
+	if strings.Contains(definition, "UPDATE OF clean_text") {
+		return nil
+	}
+
+	slog.Info("migrating tl_au trigger to only re-index updated clean text")
+	steps := []string{
+		"DROP TRIGGER IF EXISTS tl_au",
+		`CREATE TRIGGER tl_au AFTER UPDATE OF clean_text ON transcript_lines BEGIN
+			INSERT INTO transcript_search(transcript_search, rowid, clean_text) VALUES ('delete', old.rowid, old.clean_text);
+			INSERT INTO transcript_search(rowid, clean_text) VALUES (new.rowid, new.clean_text);
+		END`,
+	}
+	for _, step := range steps {
+		if _, err := tx.Exec(step); err != nil {
+			return fmt.Errorf("failed to migrate tl_au trigger: %w", err)
+		}
+	}
+	return nil
+}
+
+// Creates the transcript_trigram FTS5 table and its triggers, and indexes the existing lines, if the table doesn't exist yet.
+func createTrigramIndex(tx *sql.Tx) error {
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'transcript_trigram'").Scan(&count); err != nil {
+		return fmt.Errorf("failed to check for transcript_trigram: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	slog.Info("migrating transcript_lines to include a trigram index")
+	steps := []string{
+		`CREATE VIRTUAL TABLE transcript_trigram USING fts5(
+			text,
+			content='transcript_lines',
+			content_rowid='rowid',
+			tokenize = 'trigram'
+		)`,
+		`CREATE TRIGGER IF NOT EXISTS tl_trigram_ai AFTER INSERT ON transcript_lines BEGIN
+			INSERT INTO transcript_trigram(rowid, text) VALUES (new.rowid, new.text);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS tl_trigram_ad AFTER DELETE ON transcript_lines BEGIN
+			INSERT INTO transcript_trigram(transcript_trigram, rowid, text) VALUES ('delete', old.rowid, old.text);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS tl_trigram_au AFTER UPDATE OF text ON transcript_lines BEGIN
+			INSERT INTO transcript_trigram(transcript_trigram, rowid, text) VALUES ('delete', old.rowid, old.text);
+			INSERT INTO transcript_trigram(rowid, text) VALUES (new.rowid, new.text);
+		END`,
+		"INSERT INTO transcript_trigram(transcript_trigram) VALUES ('rebuild')",
+	}
+	for _, step := range steps {
+		if _, err := tx.Exec(step); err != nil {
+			return fmt.Errorf("failed to create trigram index: %w", err)
+		}
+	}
+	return nil
+}
+
+// Creates the transcript_words FTS5 table and its triggers, and indexes the existing lines, if the table doesn't exist yet.
+// The transcript_vocab table lists its words. Unlike transcript_search, words are not stemmed and keep their diacritics,
+// so every word of the vocabulary is spelled as it is in the clean text.
+func createWordIndex(tx *sql.Tx) error {
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'transcript_words'").Scan(&count); err != nil {
+		return fmt.Errorf("failed to check for transcript_words: %w", err)
+	}
+
+	var steps []string
+	if count == 0 {
+		slog.Info("migrating transcript_lines to include a word index")
+		steps = []string{
+			`CREATE VIRTUAL TABLE transcript_words USING fts5(
+				clean_text,
+				content='transcript_lines',
+				content_rowid='rowid',
+				tokenize = 'unicode61 remove_diacritics 0',
+				detail = none
+			)`,
+			`CREATE TRIGGER IF NOT EXISTS tl_words_ai AFTER INSERT ON transcript_lines BEGIN
+				INSERT INTO transcript_words(rowid, clean_text) VALUES (new.rowid, new.clean_text);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS tl_words_ad AFTER DELETE ON transcript_lines BEGIN
+				INSERT INTO transcript_words(transcript_words, rowid, clean_text) VALUES ('delete', old.rowid, old.clean_text);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS tl_words_au AFTER UPDATE OF clean_text ON transcript_lines BEGIN
+				INSERT INTO transcript_words(transcript_words, rowid, clean_text) VALUES ('delete', old.rowid, old.clean_text);
+				INSERT INTO transcript_words(rowid, clean_text) VALUES (new.rowid, new.clean_text);
+			END`,
+			"INSERT INTO transcript_words(transcript_words) VALUES ('rebuild')",
+		}
+	}
+	steps = append(steps, "CREATE VIRTUAL TABLE IF NOT EXISTS transcript_vocab USING fts5vocab(transcript_words, row)")
+	for _, step := range steps {
+		if _, err := tx.Exec(step); err != nil {
+			return fmt.Errorf("failed to create word index: %w", err)
+		}
+	}
+	return nil
+}
+
+// Database user_version from which the clean text of every line has segmented CJK text.
+const cjkSegmentedVersion = 1
+
+// Normalizes the clean text of lines that still have unsegmented CJK text. The FTS index is updated by the tl_au trigger.
+func resegmentCJKLines(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT rowid, text FROM transcript_lines WHERE clean_text GLOB ?", unsegmentedCJKGlob)
+	if err != nil {
+		return fmt.Errorf("failed to query unsegmented CJK lines: %w", err)
+	}
+	type line struct {
+		rowID int64
+		text  string
+	}
+	var lines []line
+	for rows.Next() {
+		var l line
+		if err := rows.Scan(&l.rowID, &l.text); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan transcript line: %w", err)
+		}
+		lines = append(lines, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error during rows iteration: %w", err)
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	slog.Info("migrating transcript_lines to segmented CJK clean text", "count", len(lines))
+	stmt, err := tx.Prepare("UPDATE transcript_lines SET clean_text = ? WHERE rowid = ?")
+	if err != nil {
+		return fmt.Errorf("failed to prepare clean text update: %w", err)
+	}
+	defer stmt.Close()
+	for _, l := range lines {
+		if _, err := stmt.Exec(normalizeText(l.text), l.rowID); err != nil {
+			return fmt.Errorf("failed to update clean text: %w", err)
+		}
+	}
+	return nil
+}
+
+// Creates revision 1 for every transcript that has no revisions, as SRT content rebuilt from its stored lines.
+func backfillRevisions(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT id FROM transcripts t WHERE NOT EXISTS (SELECT 1 FROM transcript_revisions r WHERE r.transcript_id = t.id)")
+	if err != nil {
+		return fmt.Errorf("failed to query transcripts without revisions: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan transcript id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error during rows iteration: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	slog.Info("migrating transcripts to include a first revision", "count", len(ids))
+	createdAt := time.Now().Format(time.RFC3339)
+	for _, id := range ids {
+		lineRows, err := tx.Query("SELECT start_ms, end_ms, speaker, text FROM transcript_lines WHERE transcript_id = ? ORDER BY start_ms, cue_index", id)
+		if err != nil {
+			return fmt.Errorf("failed to query lines of transcript %s: %w", id, err)
+		}
+		var lines []TranscriptLine
+		for lineRows.Next() {
+			var line TranscriptLine
+			if err := lineRows.Scan(&line.StartMs, &line.EndMs, &line.Speaker, &line.Text); err != nil {
+				lineRows.Close()
+				return fmt.Errorf("failed to scan line of transcript %s: %w", id, err)
+			}
+			lines = append(lines, line)
+		}
+		lineRows.Close()
+		if err := lineRows.Err(); err != nil {
+			return fmt.Errorf("error during rows iteration: %w", err)
+		}
+
+		input := TranscriptInput{Format: TranscriptFormatSRT, SrtTranscript: formatSRT(lines)}
+		_, err = tx.Exec("INSERT INTO transcript_revisions (transcript_id, revision, format, content, content_hash, line_count, created_at) VALUES (?, 1, ?, ?, ?, ?, ?)",
+			id, TranscriptFormatSRT, input.SrtTranscript, transcriptContentHash(&input), len(lines), createdAt)
+		if err != nil {
+			return fmt.Errorf("failed to insert first revision of transcript %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// Adds a column to the table with the given definition, unless it already exists.
+func addColumnIfMissing(tx *sql.Tx, table, column, definition string) error {
+	exists, err := columnExists(tx, table, column)
+	if err != nil || exists {
+		return err
+	}
+	slog.Info("migrating table to include column", "table", table, "column", column)
+	if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add column %s to %s: %w", column, table, err)
+	}
+	return nil
+}
+
+// Drops a column from the table, if it exists.
+func dropColumnIfExists(tx *sql.Tx, table, column string) error {
+	exists, err := columnExists(tx, table, column)
+	if err != nil || !exists {
+		return err
+	}
+	slog.Info("migrating table to drop column", "table", table, "column", column)
+	if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, column)); err != nil {
+		return fmt.Errorf("failed to drop column %s from %s: %w", column, table, err)
+	}
+	return nil
+}
+
+// Checks whether the given table has a column with the given name.
+func columnExists(tx *sql.Tx, table, column string) (bool, error) {
+	var count int
+	err := tx.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	return count > 0, nil
+}
+
 // Inserts a transcript into the database. Overwrites any existing transcript with the same ID.
-func (a *App) insertTranscript(ctx context.Context, data *TranscriptInput) error {
+// Returns TranscriptStatusCreated, TranscriptStatusUpdated, or TranscriptStatusUnchanged.
+func (a *App) insertTranscript(ctx context.Context, data *TranscriptInput) (status string, err error) {
 	// Using a transaction ensures this is an atomic operation.
 	tx, err := a.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	// 1. Manually delete transcript lines first.
-	// Explicit deletion also ensures the FTS triggers fire to clean up the search index.
-	_, err = tx.ExecContext(ctx, "DELETE FROM transcript_lines WHERE transcript_id = ?", data.ID)
+	status, err = a.insertTranscriptTx(ctx, tx, data)
 	if err != nil {
-		return fmt.Errorf("failed to delete existing transcript lines: %w", err)
+		return "", err
 	}
 
-	// 2. Delete existing transcript metadata
-	_, err = tx.ExecContext(ctx, "DELETE FROM transcripts WHERE id = ?", data.ID)
+	// Commit the transaction to save all changes.
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return status, nil
+}
+
+// Inserts a batch of transcripts in a single transaction. Each transcript is wrapped in a savepoint so that
+// one failing transcript is rolled back on its own without discarding the rest of the batch.
+// statuses and itemErrs have one entry per transcript, with the status from insertTranscript or the error
+// that rolled it back. err is set if the batch as a whole could not be saved.
+func (a *App) insertTranscriptBatch(ctx context.Context, batch []*TranscriptInput) (statuses []string, itemErrs []error, err error) {
+	tx, err := a.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to delete existing transcript: %w", err)
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	statuses = make([]string, len(batch))
+	itemErrs = make([]error, len(batch))
+	for i, data := range batch {
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT bulk_item"); err != nil {
+			return nil, nil, fmt.Errorf("failed to create savepoint: %w", err)
+		}
+		if statuses[i], itemErrs[i] = a.insertTranscriptTx(ctx, tx, data); itemErrs[i] != nil {
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO bulk_item"); err != nil {
+				return nil, nil, fmt.Errorf("failed to roll back savepoint: %w", err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, "RELEASE bulk_item"); err != nil {
+			return nil, nil, fmt.Errorf("failed to release savepoint: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit batch: %w", err)
+	}
+	return statuses, itemErrs, nil
+}
+
+// Inserts a transcript track using the given transaction. Overwrites any existing track with the same ID and language,
+// and updates the metadata shared by all tracks of the transcript.
+// If the latest upload of the track has the same content hash, its lines are left untouched and only changed metadata is written.
+// Lines that keep their start and end time keep their line ID, and their corrections if their text is still the corrected text.
+func (a *App) insertTranscriptTx(ctx context.Context, tx *sql.Tx, data *TranscriptInput) (status string, err error) {
+	contentHash := transcriptContentHash(data)
+	lang := transcriptLang(data)
+
+	// 1. Compare against the stored transcript and track, if any.
+	var existing TranscriptInput
+	var existingHash sql.NullString
+	err = tx.QueryRowContext(ctx,
+		`SELECT streamer, date, title, stream_type,
+			(SELECT content_hash FROM transcript_revisions WHERE transcript_id = t.id AND lang = ? AND kind = ? ORDER BY revision DESC LIMIT 1)
+		FROM transcripts t WHERE id = ?`,
+		lang, RevisionKindUpload, data.ID,
+	).Scan(&existing.Streamer, &existing.Date, &existing.StreamTitle, &existing.StreamType, &existingHash)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to retrieve existing transcript: %w", err)
+	}
+	if err == nil && existingHash.Valid && existingHash.String == contentHash {
+		if existing.Streamer == data.Streamer && existing.Date == data.Date &&
+			existing.StreamTitle == data.StreamTitle && existing.StreamType == data.StreamType {
+			return TranscriptStatusUnchanged, nil
+		}
+
+		// Same content with new metadata. Only the transcripts row needs to change.
+		_, err = tx.ExecContext(ctx, "UPDATE transcripts SET streamer = ?, date = ?, title = ?, stream_type = ? WHERE id = ?",
+			data.Streamer, data.Date, data.StreamTitle, data.StreamType, data.ID)
+		if err != nil {
+			return "", fmt.Errorf("failed to update transcript metadata: %w", err)
+		}
+		return TranscriptStatusUpdated, nil
 	}
 
-	// 3. Insert new transcript metadata
-	_, err = tx.ExecContext(ctx, "INSERT INTO transcripts (id, streamer, date, title, stream_type) VALUES (?, ?, ?, ?, ?)",
+	// 2. Insert or update the transcript metadata. Other tracks of the transcript are kept.
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO transcripts (id, streamer, date, title, stream_type) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET streamer = excluded.streamer, date = excluded.date, title = excluded.title, stream_type = excluded.stream_type`,
 		data.ID, data.Streamer, data.Date, data.StreamTitle, data.StreamType)
 	if err != nil {
-		return fmt.Errorf("failed to insert new transcript metadata: %w", err)
+		return "", fmt.Errorf("failed to insert transcript metadata: %w", err)
+	}
+
+	// 3. Read the line IDs and corrections of the track, then manually delete its lines.
+	// Explicit deletion also ensures the FTS triggers fire to clean up the search index.
+	previous, err := readTrackLineIDs(ctx, tx, data.ID, lang)
+	if err != nil {
+		return "", err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM transcript_lines WHERE transcript_id = ? AND lang = ?", data.ID, lang)
+	if err != nil {
+		return "", fmt.Errorf("failed to delete existing transcript lines: %w", err)
+	}
+
+	// Parse the SRT/VTT content to get individual lines.
+	lines := parseTranscriptLines(data)
+	order := transcriptOrder(lines)
+	lineIDs, corrected := previous.assign(lines, order)
+
+	// 4. Keep the uploaded content as the next revision. Revisions are numbered across all tracks of the transcript.
+	format := transcriptFormat(data)
+	content := data.SrtTranscript
+	if format == TranscriptFormatVTT {
+		content = data.VttTranscript
+	}
+	orderedIDs := make([]int64, len(order))
+	for i, index := range order {
+		orderedIDs[i] = lineIDs[index]
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO transcript_revisions (transcript_id, revision, lang, kind, format, content, content_hash, line_count, line_ids, created_at)
+		VALUES (?, (SELECT COALESCE(MAX(revision), 0) + 1 FROM transcript_revisions WHERE transcript_id = ?), ?, ?, ?, ?, ?, ?, ?, ?)`,
+		data.ID, data.ID, lang, RevisionKindUpload, format, content, contentHash, len(lines), formatLineIDs(orderedIDs), time.Now().Format(time.RFC3339))
+	if err != nil {
+		return "", fmt.Errorf("failed to insert transcript revision: %w", err)
 	}
 
-	// Parse the SRT content to get individual lines.
-	lines := parseSRTForLines(data.SrtTranscript)
 	if len(lines) == 0 {
-		// It's valid to have a transcript with no lines, so just commit metadata.
-		return tx.Commit()
+		// It's valid to have a transcript with no lines, so just keep the metadata.
+		return TranscriptStatusCreated, nil
+	}
+
+	// Prepare statement for efficient bulk insertion of transcript lines.
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO transcript_lines (transcript_id, lang, start_time, start_ms, end_ms, cue_index, line_id, speaker, text, clean_text) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return "", fmt.Errorf("failed to prepare statement for lines: %w", err)
+	}
+	defer stmt.Close()
+
+	// The cue index is the line's position in the upload, used to break ties between lines with the same start time.
+	for cueIndex, line := range lines {
+		cleanText := normalizeText(line.Text)
+		_, err := stmt.ExecContext(ctx, data.ID, lang, line.Start, line.StartMs, line.EndMs, cueIndex, lineIDs[cueIndex], line.Speaker, line.Text, cleanText)
+		if err != nil {
+			return "", fmt.Errorf("failed to insert transcript line: %w", err)
+		}
+	}
+
+	// 5. Corrections kept from the previous lines are stored as a revision of their own, after the upload.
+	if corrected {
+		if err := insertCorrectionRevision(ctx, tx, data.ID, lang); err != nil {
+			return "", err
+		}
+	}
+
+	return TranscriptStatusCreated, nil
+}
+
+// The line IDs and corrections of a track, read before its lines are overwritten.
+type trackLineIDs struct {
+	ids         map[[2]int64][]int64 // IDs of the lines with each start and end time, in transcript order
+	next        int64                // Lowest ID never given to a line of the track
+	corrections map[int64]lineCorrection
+}
+
+// The corrections made to a single line.
+type lineCorrection struct {
+	replaced []string // Every text replaced by a correction
+	text     string   // Text of the latest correction
+}
+
+// Reads the line IDs of a track, and the corrections logged for them.
+func readTrackLineIDs(ctx context.Context, tx *sql.Tx, id string, lang string) (trackLineIDs, error) {
+	track := trackLineIDs{ids: map[[2]int64][]int64{}, corrections: map[int64]lineCorrection{}}
+
+	lineRows, err := tx.QueryContext(ctx, "SELECT line_id, start_ms, end_ms FROM transcript_lines WHERE transcript_id = ? AND lang = ? ORDER BY start_ms, cue_index", id, lang)
+	if err != nil {
+		return trackLineIDs{}, fmt.Errorf("failed to query existing transcript lines: %w", err)
+	}
+	defer lineRows.Close()
+	for lineRows.Next() {
+		var lineID, startMs, endMs int64
+		if err := lineRows.Scan(&lineID, &startMs, &endMs); err != nil {
+			return trackLineIDs{}, fmt.Errorf("failed to scan existing transcript line: %w", err)
+		}
+		key := [2]int64{startMs, endMs}
+		track.ids[key] = append(track.ids[key], lineID)
+		track.next = max(track.next, lineID+1)
+	}
+	if err := lineRows.Err(); err != nil {
+		return trackLineIDs{}, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	// IDs of lines that were corrected and later removed are not given out again either.
+	editRows, err := tx.QueryContext(ctx, "SELECT CAST(line_id AS INTEGER), old_text, new_text FROM transcript_line_edits WHERE transcript_id = ? AND lang = ? ORDER BY id", id, lang)
+	if err != nil {
+		return trackLineIDs{}, fmt.Errorf("failed to query transcript line edits: %w", err)
+	}
+	defer editRows.Close()
+	for editRows.Next() {
+		var lineID int64
+		var oldText, newText string
+		if err := editRows.Scan(&lineID, &oldText, &newText); err != nil {
+			return trackLineIDs{}, fmt.Errorf("failed to scan transcript line edit: %w", err)
+		}
+		correction := track.corrections[lineID]
+		correction.replaced = append(correction.replaced, oldText)
+		correction.text = newText
+		track.corrections[lineID] = correction
+		track.next = max(track.next, lineID+1)
+	}
+	if err := editRows.Err(); err != nil {
+		return trackLineIDs{}, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return track, nil
+}
+
+// Gives each of the uploaded lines a line ID, returned in upload order. order is the transcript order of the lines.
+// A line with the same start and end time as a previous line keeps its ID, and the other lines get new IDs in transcript order.
+// A line that keeps its ID, and whose text is one that a correction replaced, gets the corrected text back.
+// corrected is true if any line was corrected.
+func (t *trackLineIDs) assign(lines []TranscriptLine, order []int) (ids []int64, corrected bool) {
+	ids = make([]int64, len(lines))
+	for _, i := range order {
+		key := [2]int64{lines[i].StartMs, lines[i].EndMs}
+		previous := t.ids[key]
+		if len(previous) == 0 {
+			ids[i] = t.next
+			t.next++
+			continue
+		}
+		ids[i] = previous[0]
+		t.ids[key] = previous[1:]
+
+		correction, ok := t.corrections[ids[i]]
+		if ok && lines[i].Text != correction.text && slices.Contains(correction.replaced, lines[i].Text) {
+			lines[i].Text = correction.text
+			corrected = true
+		}
+	}
+	return ids, corrected
+}
+
+// Returns the indexes of the lines in transcript order: by start time, with ties kept in upload order.
+func transcriptOrder(lines []TranscriptLine) []int {
+	order := make([]int, len(lines))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(x, y int) int {
+		return cmp.Compare(lines[x].StartMs, lines[y].StartMs)
+	})
+	return order
+}
+
+// Formats line IDs as a comma separated list, as stored in transcript_revisions.line_ids.
+func formatLineIDs(ids []int64) string {
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		values[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(values, ",")
+}
+
+// Stores the current lines of a track as the next revision of the transcript, so that revisions and diffs include
+// the corrections made to its lines.
+func insertCorrectionRevision(ctx context.Context, tx *sql.Tx, id string, lang string) error {
+	rows, err := tx.QueryContext(ctx, "SELECT line_id, start_ms, end_ms, speaker, text FROM transcript_lines WHERE transcript_id = ? AND lang = ? ORDER BY start_ms, cue_index", id, lang)
+	if err != nil {
+		return fmt.Errorf("failed to query transcript lines: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []TranscriptLine
+	var lineIDs []int64
+	for rows.Next() {
+		var lineID int64
+		var line TranscriptLine
+		if err := rows.Scan(&lineID, &line.StartMs, &line.EndMs, &line.Speaker, &line.Text); err != nil {
+			return fmt.Errorf("failed to scan transcript line: %w", err)
+		}
+		lines = append(lines, line)
+		lineIDs = append(lineIDs, lineID)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	input := TranscriptInput{Format: TranscriptFormatSRT, SrtTranscript: formatSRT(lines)}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO transcript_revisions (transcript_id, revision, lang, kind, format, content, content_hash, line_count, line_ids, created_at)
+		VALUES (?, (SELECT COALESCE(MAX(revision), 0) + 1 FROM transcript_revisions WHERE transcript_id = ?), ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, id, lang, RevisionKindCorrection, TranscriptFormatSRT, input.SrtTranscript, transcriptContentHash(&input), len(lines), formatLineIDs(lineIDs), time.Now().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to insert correction revision: %w", err)
+	}
+	return nil
+}
+
+// Deletes a transcript and all of its lines.
+// notFound is true if no transcript has the given ID.
+func (a *App) deleteTranscript(ctx context.Context, id string) (notFound bool, err error) {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// 1. Delete the lines explicitly so the tl_ad trigger removes them from the search index.
+	_, err = tx.ExecContext(ctx, "DELETE FROM transcript_lines WHERE transcript_id = ?", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete transcript lines: %w", err)
+	}
+
+	// 2. Delete the stored revisions.
+	_, err = tx.ExecContext(ctx, "DELETE FROM transcript_revisions WHERE transcript_id = ?", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete transcript revisions: %w", err)
+	}
+
+	// 3. Delete the edit log.
+	_, err = tx.ExecContext(ctx, "DELETE FROM transcript_line_edits WHERE transcript_id = ?", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete transcript line edits: %w", err)
+	}
+
+	// 4. Delete the transcript metadata.
+	res, err := tx.ExecContext(ctx, "DELETE FROM transcripts WHERE id = ?", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete transcript: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get deleted rows: %w", err)
+	}
+	if affected == 0 {
+		return true, fmt.Errorf("transcript with id '%s' not found", id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return false, nil
+}
+
+// Updates the metadata of a transcript without touching its lines. Only the fields set in the patch are changed.
+// notFound and err are used to differentiate between a 400 and 500 error.
+// notFound && err -> 404 | !notFound && err -> 500 | !notFound && !err -> 200
+func (a *App) updateTranscriptMetadata(ctx context.Context, id string, patch *TranscriptPatch) (metadataOutput StreamMetadataOutput, notFound bool, err error) {
+	// A nil field is passed as NULL, which keeps the current value.
+	row := a.db.QueryRowContext(ctx,
+		`UPDATE transcripts SET
+			streamer = COALESCE(?, streamer),
+			date = COALESCE(?, date),
+			title = COALESCE(?, title),
+			stream_type = COALESCE(?, stream_type)
+		WHERE id = ?
+		RETURNING id, streamer, date, title, stream_type`,
+		patch.Streamer, patch.Date, patch.StreamTitle, patch.StreamType, id,
+	)
+	err = row.Scan(&metadataOutput.ID, &metadataOutput.Streamer, &metadataOutput.Date, &metadataOutput.StreamTitle, &metadataOutput.StreamType)
+	if err == sql.ErrNoRows {
+		return StreamMetadataOutput{}, true, fmt.Errorf("transcript with id '%s' not found", id)
+	}
+	if err != nil {
+		return StreamMetadataOutput{}, false, fmt.Errorf("failed to update transcript metadata: %w", err)
+	}
+
+	return metadataOutput, false, nil
+}
+
+// Replaces the text of a single line, found by the line ID returned by retrieveTranscriptTrack for the same track.
+// An empty lang selects the default track. The edit is logged with the editor and time, and the corrected lines
+// of the track are stored as a new revision. Returns the updated line.
+// notFound and err are used to differentiate between a 400 and 500 error.
+// notFound && err -> 404 | !notFound && err -> 500 | !notFound && !err -> 200
+func (a *App) updateTranscriptLine(ctx context.Context, id string, lang string, lineID string, text string, editor string) (line TranscriptLine, notFound bool, err error) {
+	// Line IDs are kept by the line across uploads, see insertTranscriptTx.
+	storedID, err := strconv.ParseInt(lineID, 10, 64)
+	if err != nil {
+		return TranscriptLine{}, true, fmt.Errorf("line '%s' of transcript '%s' not found", lineID, id)
+	}
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return TranscriptLine{}, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// 1. Find the line.
+	lang, _, notFound, err = resolveTrack(ctx, tx, id, lang)
+	if err != nil {
+		return TranscriptLine{}, notFound, err
+	}
+	var rowID int64
+	var oldText string
+	line.ID = lineID
+	err = tx.QueryRowContext(ctx,
+		"SELECT rowid, start_time, start_ms, end_ms, speaker, text FROM transcript_lines WHERE transcript_id = ? AND lang = ? AND line_id = ?",
+		id, lang, storedID,
+	).Scan(&rowID, &line.Start, &line.StartMs, &line.EndMs, &line.Speaker, &oldText)
+	if err == sql.ErrNoRows {
+		return TranscriptLine{}, true, fmt.Errorf("line '%s' of transcript '%s' not found", lineID, id)
+	}
+	if err != nil {
+		return TranscriptLine{}, false, fmt.Errorf("failed to retrieve transcript line: %w", err)
+	}
+
+	// 2. Update the text. The tl_au trigger re-indexes the new clean text.
+	_, err = tx.ExecContext(ctx, "UPDATE transcript_lines SET text = ?, clean_text = ? WHERE rowid = ?", text, normalizeText(text), rowID)
+	if err != nil {
+		return TranscriptLine{}, false, fmt.Errorf("failed to update transcript line: %w", err)
 	}
 
-	// Prepare statement for efficient bulk insertion of transcript lines.
-	stmt, err := tx.PrepareContext(ctx, "INSERT INTO transcript_lines (transcript_id, start_time, text, clean_text) VALUES (?, ?, ?, ?)")
+	// 3. Log the edit against the current revision of the track.
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO transcript_line_edits (transcript_id, lang, revision, line_id, old_text, new_text, editor, edited_at)
+		VALUES (?, ?, (SELECT COALESCE(MAX(revision), 0) FROM transcript_revisions WHERE transcript_id = ? AND lang = ?), ?, ?, ?, ?, ?)`,
+		id, lang, id, lang, lineID, oldText, text, editor, time.Now().Format(time.RFC3339))
 	if err != nil {
-		return fmt.Errorf("failed to prepare statement for lines: %w", err)
+		return TranscriptLine{}, false, fmt.Errorf("failed to log transcript line edit: %w", err)
 	}
-	defer stmt.Close()
 
-	for _, line := range lines {
-		cleanText := normalizeText(line.Text)
-		_, err := stmt.ExecContext(ctx, data.ID, line.Start, line.Text, cleanText)
-		if err != nil {
-			return fmt.Errorf("failed to insert transcript line: %w", err)
-		}
+	// 4. Store the corrected track as the next revision.
+	if err := insertCorrectionRevision(ctx, tx, id, lang); err != nil {
+		return TranscriptLine{}, false, err
 	}
 
-	// Commit the transaction to save all changes.
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return TranscriptLine{}, false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	line.Text = text
+	return line, false, nil
 }
 
-// Retrieves a transcript from the database with the given ID.
+// Retrieves the default track of a transcript from the database with the given ID.
 // notFound and err are used to differentiate between a 400 and 500 error.
 // notFound && err -> 404 | !notFound && err -> 500 | !notFound && !err -> 200
 func (a *App) retrieveTranscript(ctx context.Context, id string) (transcriptOutput TranscriptOutput, notFound bool, err error) {
+	return a.retrieveTranscriptTrack(ctx, id, "")
+}
+
+// Retrieves one track of a transcript from the database with the given ID. An empty lang selects the default track.
+// notFound and err are used to differentiate between a 400 and 500 error.
+// notFound && err -> 404 | !notFound && err -> 500 | !notFound && !err -> 200
+func (a *App) retrieveTranscriptTrack(ctx context.Context, id string, lang string) (transcriptOutput TranscriptOutput, notFound bool, err error) {
+	return a.retrieveTranscriptWindow(ctx, id, lang, TranscriptWindow{})
+}
+
+// Retrieves the lines of one track of a transcript that are in the window, with the same line IDs and TotalLines as the whole track.
+// An empty lang selects the default track.
+// notFound and err are used to differentiate between a 400 and 500 error.
+// notFound && err -> 404 | !notFound && err -> 500 | !notFound && !err -> 200
+func (a *App) retrieveTranscriptWindow(ctx context.Context, id string, lang string, window TranscriptWindow) (transcriptOutput TranscriptOutput, notFound bool, err error) {
 	row := a.db.QueryRowContext(ctx,
 		"SELECT id, streamer, date, title, stream_type FROM transcripts WHERE id = ?",
 		id,
@@ -233,22 +1129,68 @@ func (a *App) retrieveTranscript(ctx context.Context, id string) (transcriptOutp
 		return TranscriptOutput{}, true, fmt.Errorf("transcript with id '%s' not found", id)
 	}
 
-	// Retrieve all lines for the transcript, ordered by time.
-	rows, err := a.db.QueryContext(ctx, "SELECT start_time, text FROM transcript_lines WHERE transcript_id = ? ORDER BY start_time", id) // Use QueryContext
+	// Select the track.
+	transcriptOutput.Lang, transcriptOutput.Langs, notFound, err = resolveTrack(ctx, a.db, id, lang)
+	if err != nil {
+		return TranscriptOutput{}, notFound, err
+	}
+	err = a.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(revision), 0) FROM transcript_revisions WHERE transcript_id = ? AND lang = ?", id, transcriptOutput.Lang).
+		Scan(&transcriptOutput.Revision)
+	if err != nil {
+		return TranscriptOutput{}, false, fmt.Errorf("failed to retrieve current revision: %w", err)
+	}
+
+	// Count the lines of the track, and those started by Around to find the line being said then.
+	var startedLines int
+	var around any
+	if window.Around != nil {
+		around = *window.Around
+	}
+	err = a.db.QueryRowContext(ctx, "SELECT COUNT(*), COUNT(CASE WHEN start_ms <= ? THEN 1 END) FROM transcript_lines WHERE transcript_id = ? AND lang = ?", around, id, transcriptOutput.Lang).
+		Scan(&transcriptOutput.TotalLines, &startedLines)
+	if err != nil {
+		return TranscriptOutput{}, false, fmt.Errorf("failed to count transcript lines: %w", err)
+	}
+
+	// Retrieve the lines for the window, ordered by time. Matches TranscriptWindow.slice over the whole track.
+	var qParams strings.Builder
+	sqlArgs := []any{id, transcriptOutput.Lang}
+	qParams.WriteString("SELECT line_id, start_time, start_ms, end_ms, speaker, text FROM transcript_lines WHERE transcript_id = ? AND lang = ?")
+	if window.Around == nil {
+		if window.From != nil {
+			qParams.WriteString(" AND start_ms >= ?")
+			sqlArgs = append(sqlArgs, *window.From)
+		}
+		if window.To != nil {
+			qParams.WriteString(" AND start_ms < ?")
+			sqlArgs = append(sqlArgs, endOfSecond(*window.To))
+		}
+	}
+	qParams.WriteString(" ORDER BY start_ms, cue_index")
+	if window.Around != nil {
+		// The line being said is the last one to start by then, or the first line if none has.
+		i := max(startedLines-1, 0)
+		start := max(i-window.Lines, 0)
+		qParams.WriteString(" LIMIT ? OFFSET ?")
+		sqlArgs = append(sqlArgs, i+window.Lines+1-start, start)
+	}
+	rows, err := a.db.QueryContext(ctx, qParams.String(), sqlArgs...)
 	if err != nil {
 		return TranscriptOutput{}, false, fmt.Errorf("failed to query transcript lines: %w", err)
 	}
 	defer rows.Close()
 
 	var lines []TranscriptLine
-	lineId := 0
+	if window.From != nil || window.To != nil {
+		lines = []TranscriptLine{}
+	}
 	for rows.Next() {
 		var line TranscriptLine
-		if err := rows.Scan(&line.Start, &line.Text); err != nil {
+		var lineID int64
+		if err := rows.Scan(&lineID, &line.Start, &line.StartMs, &line.EndMs, &line.Speaker, &line.Text); err != nil {
 			return TranscriptOutput{}, false, fmt.Errorf("failed to scan transcript line: %w", err)
 		}
-		line.ID = fmt.Sprintf("%d", lineId)
-		lineId++
+		line.ID = strconv.FormatInt(lineID, 10)
 		lines = append(lines, line)
 	}
 
@@ -260,6 +1202,237 @@ func (a *App) retrieveTranscript(ctx context.Context, id string) (transcriptOutp
 	return transcriptOutput, false, nil
 }
 
+// Queries the database, or a transaction within it.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Returns the language of the requested track, and the languages of all tracks of the transcript, in upload order.
+// An empty lang selects the default track, which is the first track uploaded, and untaggedLang the track without a language.
+// notFound is true if the transcript has no track with the requested language.
+func resolveTrack(ctx context.Context, q queryer, id string, lang string) (track string, langs []string, notFound bool, err error) {
+	rows, err := q.QueryContext(ctx, "SELECT lang FROM transcript_revisions WHERE transcript_id = ? GROUP BY lang ORDER BY MIN(revision)", id)
+	if err != nil {
+		return "", nil, false, fmt.Errorf("failed to query transcript tracks: %w", err)
+	}
+	defer rows.Close()
+
+	langs = []string{}
+	for rows.Next() {
+		var l string
+		if err := rows.Scan(&l); err != nil {
+			return "", nil, false, fmt.Errorf("failed to scan transcript track: %w", err)
+		}
+		langs = append(langs, l)
+	}
+	if err := rows.Err(); err != nil {
+		return "", nil, false, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	if lang == "" {
+		if len(langs) > 0 {
+			return langs[0], langs, false, nil
+		}
+		return "", langs, false, nil
+	}
+	lang = storedLang(lang)
+	if !slices.Contains(langs, lang) {
+		return "", nil, true, fmt.Errorf("track '%s' of transcript '%s' not found", lang, id)
+	}
+	return lang, langs, false, nil
+}
+
+// Retrieves an older upload of a transcript. The lines are parsed from the content stored with the revision,
+// with the current metadata of the transcript.
+// notFound and err are used to differentiate between a 400 and 500 error.
+// notFound && err -> 404 | !notFound && err -> 500 | !notFound && !err -> 200
+func (a *App) retrieveTranscriptRevision(ctx context.Context, id string, revision int) (transcriptOutput TranscriptOutput, notFound bool, err error) {
+	// The metadata lookup also enforces membership access.
+	metadata, notFound, err := a.retrieveStreamMetadata(ctx, id)
+	if err != nil {
+		return TranscriptOutput{}, notFound, err
+	}
+
+	lang, lines, notFound, err := a.retrieveRevisionLines(ctx, id, revision)
+	if err != nil {
+		return TranscriptOutput{}, notFound, err
+	}
+	_, langs, _, err := resolveTrack(ctx, a.db, id, lang)
+	if err != nil {
+		return TranscriptOutput{}, false, err
+	}
+
+	return TranscriptOutput{
+		Streamer:        metadata.Streamer,
+		Date:            metadata.Date,
+		StreamType:      metadata.StreamType,
+		StreamTitle:     metadata.StreamTitle,
+		ID:              metadata.ID,
+		Lang:            lang,
+		Langs:           langs,
+		Revision:        revision,
+		TotalLines:      len(lines),
+		TranscriptLines: lines,
+	}, false, nil
+}
+
+// Parses the lines of a stored revision, in the same order and with the same line IDs as retrieveTranscriptTrack.
+// Also returns the language of the revision's track. Does not check membership access.
+func (a *App) retrieveRevisionLines(ctx context.Context, id string, revision int) (lang string, lines []TranscriptLine, notFound bool, err error) {
+	var input TranscriptInput
+	var content, lineIDs string
+	err = a.db.QueryRowContext(ctx, "SELECT lang, format, content, line_ids FROM transcript_revisions WHERE transcript_id = ? AND revision = ?", id, revision).
+		Scan(&lang, &input.Format, &content, &lineIDs)
+	if err == sql.ErrNoRows {
+		return "", nil, true, fmt.Errorf("revision %d of transcript '%s' not found", revision, id)
+	}
+	if err != nil {
+		return "", nil, false, fmt.Errorf("failed to retrieve transcript revision: %w", err)
+	}
+	if input.Format == TranscriptFormatVTT {
+		input.VttTranscript = content
+	} else {
+		input.SrtTranscript = content
+	}
+
+	// Lines are stored and returned ordered by start time, with ties kept in upload order.
+	parsed := parseTranscriptLines(&input)
+	lines = make([]TranscriptLine, len(parsed))
+	for i, index := range transcriptOrder(parsed) {
+		lines[i] = parsed[index]
+	}
+
+	// Revisions stored before line IDs were kept are numbered by position, like their lines were.
+	ids := strings.Split(lineIDs, ",")
+	for i := range lines {
+		if len(ids) == len(lines) {
+			lines[i].ID = ids[i]
+		} else {
+			lines[i].ID = strconv.Itoa(i)
+		}
+	}
+	return lang, lines, false, nil
+}
+
+// Retrieves the list of stored revisions of a transcript.
+// notFound and err are used to differentiate between a 400 and 500 error.
+// notFound && err -> 404 | !notFound && err -> 500 | !notFound && !err -> 200
+func (a *App) retrieveTranscriptRevisions(ctx context.Context, id string) (revisionsOutput TranscriptRevisionsOutput, notFound bool, err error) {
+	// The metadata lookup also enforces membership access.
+	if _, notFound, err := a.retrieveStreamMetadata(ctx, id); err != nil {
+		return TranscriptRevisionsOutput{}, notFound, err
+	}
+
+	rows, err := a.db.QueryContext(ctx, "SELECT revision, lang, kind, format, line_count, created_at FROM transcript_revisions WHERE transcript_id = ? ORDER BY revision", id)
+	if err != nil {
+		return TranscriptRevisionsOutput{}, false, fmt.Errorf("failed to query transcript revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisionsOutput = TranscriptRevisionsOutput{ID: id, Revisions: []TranscriptRevision{}}
+	for rows.Next() {
+		var rev TranscriptRevision
+		if err := rows.Scan(&rev.Revision, &rev.Lang, &rev.Kind, &rev.Format, &rev.Lines, &rev.CreatedAt); err != nil {
+			return TranscriptRevisionsOutput{}, false, fmt.Errorf("failed to scan transcript revision: %w", err)
+		}
+		revisionsOutput.Revisions = append(revisionsOutput.Revisions, rev)
+		revisionsOutput.Current = rev.Revision
+	}
+
+	if err := rows.Err(); err != nil {
+		return TranscriptRevisionsOutput{}, false, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return revisionsOutput, false, nil
+}
+
+// Retrieves the log of corrections made to the lines of a transcript track. An empty lang selects the default track.
+// notFound and err are used to differentiate between a 400 and 500 error.
+// notFound && err -> 404 | !notFound && err -> 500 | !notFound && !err -> 200
+func (a *App) retrieveTranscriptEdits(ctx context.Context, id string, lang string) (editsOutput TranscriptEditsOutput, notFound bool, err error) {
+	// The metadata lookup also enforces membership access.
+	if _, notFound, err := a.retrieveStreamMetadata(ctx, id); err != nil {
+		return TranscriptEditsOutput{}, notFound, err
+	}
+
+	editsOutput = TranscriptEditsOutput{ID: id, Edits: []LineEdit{}}
+	editsOutput.Lang, _, notFound, err = resolveTrack(ctx, a.db, id, lang)
+	if err != nil {
+		return TranscriptEditsOutput{}, notFound, err
+	}
+
+	rows, err := a.db.QueryContext(ctx,
+		"SELECT line_id, revision, old_text, new_text, editor, edited_at FROM transcript_line_edits WHERE transcript_id = ? AND lang = ? ORDER BY id",
+		id, editsOutput.Lang)
+	if err != nil {
+		return TranscriptEditsOutput{}, false, fmt.Errorf("failed to query transcript line edits: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var edit LineEdit
+		if err := rows.Scan(&edit.LineID, &edit.Revision, &edit.OldText, &edit.NewText, &edit.Editor, &edit.EditedAt); err != nil {
+			return TranscriptEditsOutput{}, false, fmt.Errorf("failed to scan transcript line edit: %w", err)
+		}
+		editsOutput.Edits = append(editsOutput.Edits, edit)
+	}
+
+	if err := rows.Err(); err != nil {
+		return TranscriptEditsOutput{}, false, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return editsOutput, false, nil
+}
+
+// Returned by diffTranscriptRevisions when the two revisions belong to different tracks.
+var errRevisionTrackMismatch = errors.New("revisions belong to different tracks")
+
+// Compares the lines of two revisions of a transcript. A `to` of 0 compares against the current revision.
+// Both revisions must belong to the same track, otherwise errRevisionTrackMismatch is returned.
+// notFound and err are used to differentiate between a 400 and 500 error.
+// notFound && err -> 404 | !notFound && err -> 500 | !notFound && !err -> 200
+func (a *App) diffTranscriptRevisions(ctx context.Context, id string, from, to int) (diffOutput TranscriptDiffOutput, notFound bool, err error) {
+	// The metadata lookup also enforces membership access.
+	if _, notFound, err := a.retrieveStreamMetadata(ctx, id); err != nil {
+		return TranscriptDiffOutput{}, notFound, err
+	}
+
+	lang, fromLines, notFound, err := a.retrieveRevisionLines(ctx, id, from)
+	if err != nil {
+		return TranscriptDiffOutput{}, notFound, err
+	}
+
+	// Compare against the current revision of the same track by default.
+	if to == 0 {
+		err = a.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(revision), 0) FROM transcript_revisions WHERE transcript_id = ? AND lang = ?", id, lang).Scan(&to)
+		if err != nil {
+			return TranscriptDiffOutput{}, false, fmt.Errorf("failed to retrieve current revision: %w", err)
+		}
+	}
+
+	toLang, toLines, notFound, err := a.retrieveRevisionLines(ctx, id, to)
+	if err != nil {
+		return TranscriptDiffOutput{}, notFound, err
+	}
+	if toLang != lang {
+		return TranscriptDiffOutput{}, false, fmt.Errorf("%w: revision %d is in track '%s', revision %d is in track '%s'", errRevisionTrackMismatch, from, lang, to, toLang)
+	}
+
+	changes, unchanged := diffLines(fromLines, toLines)
+	diffOutput = TranscriptDiffOutput{ID: id, From: from, To: to, Unchanged: unchanged, Changes: changes}
+	if diffOutput.Changes == nil {
+		diffOutput.Changes = []LineChange{}
+	}
+	for _, change := range changes {
+		if change.Op == LineChangeAdded {
+			diffOutput.Added++
+		} else {
+			diffOutput.Removed++
+		}
+	}
+	return diffOutput, false, nil
+}
+
 // Retrieves a stream's metadata from the database with the given ID.
 // notFound and err are used to differentiate between a 400 and 500 error.
 // notFound && err -> 404 | !notFound && err -> 500 | !notFound && !err -> 200
@@ -289,9 +1462,14 @@ func (a *App) retrieveStreamMetadata(ctx context.Context, id string) (metadataOu
 	return output, false, nil
 }
 
-// Retrieves all available streams in the database.
-func (a *App) retrieveAllStreams(ctx context.Context) ([]StreamMetadataOutput, error) {
-	rows, err := a.db.QueryContext(ctx, "SELECT id, streamer, date, title, stream_type FROM transcripts ORDER BY date DESC")
+// Retrieves all available streams in the database that match the stream filters of the query data.
+// Stream metadata is public, so Members streams are listed too.
+func (a *App) retrieveAllStreams(ctx context.Context, queryData QueryData) ([]StreamMetadataOutput, error) {
+	var qParams strings.Builder
+	var sqlArgs []any
+	buildStreamFilterQuery(&qParams, &sqlArgs, queryData)
+
+	rows, err := a.db.QueryContext(ctx, "SELECT id, streamer, date, title, stream_type FROM transcripts t WHERE 1=1"+qParams.String()+" ORDER BY date DESC", sqlArgs...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query all streams: %w", err)
 	}
@@ -314,37 +1492,161 @@ func (a *App) retrieveAllStreams(ctx context.Context) ([]StreamMetadataOutput, e
 }
 
 // Retrieves a list of screams and a list of contexts that matches the query based on the given query data.
-// Contexts are empty if searchText is empty.
-func (a *App) queryTranscripts(ctx context.Context, queryData QueryData) (TranscriptSearchOutput, error) {
-	// 1. Grab all the streams that match the query, ignoring searchText. Part 2 will refine the results based on searchText. This is done to avoid searching unnecessary streams.
+// Contexts are empty if searchText is empty. Regex searches return errSearchTimeout if they run out of time.
+// Fuzzy searches report the variants of their terms, and text and fuzzy searches without results suggest corrected search texts.
+func (a *App) queryTranscripts(ctx context.Context, queryData QueryData) (output TranscriptSearchOutput, err error) {
+	if queryData.SearchMode == SearchModeRegex {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, regexSearchTimeout)
+		defer cancel()
+		defer func() { err = searchTimeoutError(ctx, err) }()
+	}
 
-	// --- Build Metadata Query ---
+	var search searchQuery
+	if queryData.SearchText != "" {
+		search, err = a.parseQueryDataSearch(ctx, queryData)
+		if err != nil {
+			return TranscriptSearchOutput{}, err
+		}
+	}
+
+	output, err = a.searchTranscripts(ctx, queryData, search)
+	if err != nil || queryData.SearchText == "" {
+		return output, err
+	}
+
+	if queryData.SearchMode == SearchModeFuzzy {
+		var lines []string
+		for _, res := range output.Result {
+			for _, context := range res.Contexts {
+				lines = append(lines, context.Line)
+			}
+		}
+		output.Fuzzy = search.fuzzyTerms(lines)
+		if err := a.filterFuzzyVariants(ctx, queryData, output.Fuzzy); err != nil {
+			return TranscriptSearchOutput{}, err
+		}
+	}
+	if len(output.Result) == 0 && queryData.SearchMode != SearchModeRegex {
+		output.Suggestions, err = a.searchSuggestions(ctx, queryData, search)
+		if err != nil {
+			return TranscriptSearchOutput{}, err
+		}
+	}
+	return output, nil
+}
+
+// Builds the FROM and WHERE clauses of a search, with its args: the lines matching the search text and the line filters,
+// joined to their transcripts (aliased t) matching the stream filters. Without search text, only the transcripts are selected.
+func buildSearchFrom(queryData QueryData, search searchQuery) (string, []any) {
 	var qParams strings.Builder
 	var sqlArgs []any
 	buildFilterQuery(&qParams, &sqlArgs, queryData) // Builds WHERE clause for filters
 
-	var query strings.Builder
-	query.WriteString("SELECT t.id, t.streamer, t.date, t.title, t.stream_type FROM transcripts t")
+	var from strings.Builder
+	switch {
+	case queryData.SearchText != "" && queryData.SearchMode != SearchModeRegex:
+		// The FTS5 table has to be scanned first for its rank to be available, and CROSS JOIN keeps it first.
+		from.WriteString(" FROM transcript_search ts CROSS JOIN transcript_lines tl ON tl.rowid = ts.rowid JOIN transcripts t ON t.id = tl.transcript_id")
+	case queryData.SearchText != "":
+		from.WriteString(" FROM transcripts t JOIN transcript_lines tl ON t.id = tl.transcript_id")
+	default:
+		from.WriteString(" FROM transcripts t")
+	}
 
-	var ftsQuery string
 	if queryData.SearchText != "" {
-		ftsQuery = buildFTSQuery(queryData.SearchText) // Creates `"clean search text"`
-		query.WriteString(`
-			JOIN transcript_lines tl ON t.id = tl.transcript_id
-			JOIN transcript_search ts ON tl.rowid = ts.rowid
-		`)
-		qParams.WriteString(" AND ts.clean_text MATCH ?")
-		sqlArgs = append(sqlArgs, ftsQuery)
+		buildSearchQuery(nil, &qParams, &sqlArgs, queryData, search) // FTS match on clean text, or regex on the original text
+		if queryData.MatchWholeWord && queryData.SearchMode != SearchModeRegex {
+			search.buildWholeWordQuery(&qParams, &sqlArgs) // Pages and totals only count whole word matches
+		}
+		buildLineFilterQuery(&qParams, &sqlArgs, queryData)
+	} else if hasLineFilters(queryData) {
+		// Without search text, keep the transcripts that have any line matching the line filters.
+		qParams.WriteString(" AND EXISTS (SELECT 1 FROM transcript_lines tl WHERE tl.transcript_id = t.id")
+		buildLineFilterQuery(&qParams, &sqlArgs, queryData)
+		qParams.WriteString(")")
 	}
+	from.WriteString(qParams.String())
+	return from.String(), sqlArgs
+}
 
-	query.WriteString(qParams.String())
+// Checks whether a search has any result, without counting or fetching them.
+func (a *App) searchHasResults(ctx context.Context, queryData QueryData, search searchQuery) (bool, error) {
+	from, sqlArgs := buildSearchFrom(queryData, search)
+	var found bool
+	if err := a.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1"+from+")", sqlArgs...).Scan(&found); err != nil {
+		return false, fmt.Errorf("failed to check search results: %w", err)
+	}
+	return found, nil
+}
+
+// Runs the search of queryTranscripts, with the search text already parsed into search.
+func (a *App) searchTranscripts(ctx context.Context, queryData QueryData, search searchQuery) (TranscriptSearchOutput, error) {
+	// 1. Grab the page of streams that match the query. Part 2 fetches the contexts of just those streams.
+
+	// --- Build Metadata Query ---
+	from, sqlArgs := buildSearchFrom(queryData, search)
+
+	// --- Count the matches of every page ---
+	output := TranscriptSearchOutput{Result: make([]*TranscriptSearch, 0)}
+	countQuery := "SELECT COUNT(*), 0" + from
+	if queryData.SearchText != "" {
+		countQuery = "SELECT COUNT(DISTINCT t.id), COUNT(*)" + from
+	}
+	if err := a.db.QueryRowContext(ctx, countQuery, sqlArgs...).Scan(&output.Total, &output.TotalContexts); err != nil {
+		return TranscriptSearchOutput{}, fmt.Errorf("failed to count transcripts: %w", err)
+	}
+	if len(queryData.Facets) > 0 {
+		var err error
+		output.Facets, err = a.countFacets(ctx, queryData, from, sqlArgs)
+		if err != nil {
+			return TranscriptSearchOutput{}, err
+		}
+	}
+
+	// --- Build Page Query ---
+	order := searchSortOrder(queryData)
+	cursor, err := order.decodeCursor(queryData.Cursor)
+	if err != nil {
+		return TranscriptSearchOutput{}, err
+	}
+	limit := queryData.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	query := "SELECT t.id, t.streamer, t.date, t.title, t.stream_type, 0 AS matches, 0 AS score" + from
+	if queryData.SearchText != "" {
+		// Matching lines of each transcript, and the sum of their FTS5 ranks. The rank is bm25(), which is lower for better matches.
+		// Regex searches don't use FTS5 and are scored by their number of matching lines.
+		score := "-SUM(ts.rank)"
+		if queryData.SearchMode == SearchModeRegex {
+			score = "COUNT(*)"
+		}
+		query = "SELECT t.id, t.streamer, t.date, t.title, t.stream_type, COUNT(*) AS matches, " + score + " AS score" + from
+	}
+	pageArgs := slices.Clone(sqlArgs)
+	var after string
+	var afterArgs []any
+	if cursor != nil {
+		after, afterArgs = order.after(cursor)
+	}
+	if cursor != nil && !order.Aggregate {
+		query += " AND" + after
+		pageArgs = append(pageArgs, afterArgs...)
+	}
 	if queryData.SearchText != "" {
-		query.WriteString(" GROUP BY t.id")
+		query += " GROUP BY t.id"
 	}
-	query.WriteString(" ORDER BY t.date DESC")
+	if cursor != nil && order.Aggregate {
+		query += " HAVING" + after
+		pageArgs = append(pageArgs, afterArgs...)
+	}
+	query += order.orderBy() + " LIMIT ?" // One more than the page, to know if there is a next page
+	pageArgs = append(pageArgs, limit+1)
 
 	// --- Execute Metadata Query ---
-	rows, err := a.db.QueryContext(ctx, query.String(), sqlArgs...)
+	rows, err := a.db.QueryContext(ctx, query, pageArgs...)
 	if err != nil {
 		return TranscriptSearchOutput{}, fmt.Errorf("failed to query transcripts metadata: %w", err)
 	}
@@ -355,7 +1657,7 @@ func (a *App) queryTranscripts(ctx context.Context, queryData QueryData) (Transc
 
 	for rows.Next() {
 		var res TranscriptSearch
-		if err := rows.Scan(&res.ID, &res.Streamer, &res.Date, &res.Title, &res.StreamType); err != nil {
+		if err := rows.Scan(&res.ID, &res.Streamer, &res.Date, &res.Title, &res.StreamType, &res.TotalContexts, &res.Score); err != nil {
 			rows.Close()
 			return TranscriptSearchOutput{}, fmt.Errorf("failed to scan metadata row: %w", err)
 		}
@@ -363,19 +1665,33 @@ func (a *App) queryTranscripts(ctx context.Context, queryData QueryData) (Transc
 
 		resPtr := &res
 		resultsList = append(resultsList, resPtr)
-		resultsMap[res.ID] = resPtr
-		idArgs = append(idArgs, res.ID)
 	}
 	rows.Close()
+	if err := rows.Err(); err != nil {
+		return TranscriptSearchOutput{}, fmt.Errorf("error iterating metadata rows: %w", err)
+	}
+
+	if len(resultsList) > limit {
+		resultsList = resultsList[:limit]
+		output.NextCursor = order.encodeCursor(resultsList[limit-1])
+	}
+	for _, res := range resultsList {
+		resultsMap[res.ID] = res
+		idArgs = append(idArgs, res.ID)
+	}
+	output.Result = resultsList
 
 	if len(resultsList) == 0 {
-		return TranscriptSearchOutput{Result: resultsList}, nil
+		return output, nil
 	}
 
-	// 2. Create a single query to fetch all the contexts for every stream found in part 1.
-	// Also filter out any streams that don't have any contexts.
+	// 2. Create a single query to fetch a page of contexts for every stream found in part 1.
 
 	if queryData.SearchText != "" {
+		searchRe, countColumn, err := a.getCountRegex(queryData, search)
+		if err != nil {
+			return TranscriptSearchOutput{}, fmt.Errorf("failed to compile regex: %w", err)
+		}
 		inQuery := strings.Repeat("?,", len(idArgs)-1) + "?"
 
 		// --- Build the context query dynamically ---
@@ -383,41 +1699,91 @@ func (a *App) queryTranscripts(ctx context.Context, queryData QueryData) (Transc
 		contextQuery.WriteString(`
 			WITH RankedContexts AS (
 				SELECT
+					tl.rowid AS line_rowid,
 					tl.transcript_id,
 					tl.start_time,
+					tl.start_ms,
+					tl.cue_index,
+					tl.lang,
+					tl.speaker,
 					tl.text, -- Only need original text now
 					ROW_NUMBER() OVER(
 						PARTITION BY tl.transcript_id
-						ORDER BY tl.start_time ASC
+						ORDER BY tl.start_ms ASC, tl.cue_index ASC
 					) as rn
-				FROM transcript_lines tl
-				JOIN transcript_search ts ON tl.rowid = ts.rowid
-				WHERE ts.clean_text MATCH ?  -- FTS match on clean text
-				  AND tl.transcript_id IN (%s) -- Match transcript IDs
-		`) // Note: inQuery will be interpolated later
+				FROM transcript_lines tl`)
 
-		var wholeWordRegexPattern string
-		if queryData.MatchWholeWord {
-			wholeWordRegexPattern = `(?i)\b` + regexp.QuoteMeta(queryData.SearchText) + `\b`
-			contextQuery.WriteString(" AND regexp(?, tl.text)") // Add regexp check
+		var searchFilters strings.Builder
+		var searchArgs []any
+		buildSearchQuery(&contextQuery, &searchFilters, &searchArgs, queryData, search) // FTS match on clean text, or regex on the original text
+		if queryData.MatchWholeWord && queryData.SearchMode != SearchModeRegex {
+			search.buildWholeWordQuery(&searchFilters, &searchArgs) // Add regexp check
 		}
 
 		contextQuery.WriteString(`
+				WHERE tl.transcript_id IN (%s) -- Match transcript IDs
+		`) // Note: inQuery will be interpolated later
+		contextQuery.WriteString(searchFilters.String())
+
+		var lineFilters strings.Builder
+		var lineFilterArgs []any
+		buildLineFilterQuery(&lineFilters, &lineFilterArgs, queryData)
+		contextQuery.WriteString(lineFilters.String())
+
+		if queryData.Before == 0 && queryData.After == 0 {
+			contextQuery.WriteString(`
 			)
-			SELECT transcript_id, start_time, text -- Select only needed columns
+			SELECT transcript_id, 0 AS line_offset, start_time, lang, speaker, text -- Select only needed columns
 			FROM RankedContexts
-			WHERE rn <= 20
-			ORDER BY transcript_id, start_time;
+			WHERE rn > ? AND rn <= ?
+			ORDER BY transcript_id, start_ms, cue_index;
+		`)
+		} else {
+			// Each context is returned with its neighbouring lines in the same track, found by their position in the track.
+			// line_offset is the position of the line relative to the context, which is 0 for the context itself.
+			contextQuery.WriteString(`
+			),
+			PageContexts AS (
+				SELECT * FROM RankedContexts WHERE rn > ? AND rn <= ?
+			),
+			TrackLines AS (
+				SELECT
+					l.rowid AS line_rowid,
+					l.transcript_id,
+					l.lang,
+					l.start_time,
+					l.speaker,
+					l.text,
+					ROW_NUMBER() OVER(
+						PARTITION BY l.transcript_id, l.lang
+						ORDER BY l.start_ms ASC, l.cue_index ASC
+					) as pos
+				FROM transcript_lines l
+				WHERE l.transcript_id IN (SELECT transcript_id FROM PageContexts)
+			)
+			SELECT c.transcript_id, n.pos - p.pos AS line_offset, n.start_time, c.lang, n.speaker, n.text
+			FROM PageContexts c
+			JOIN TrackLines p ON p.line_rowid = c.line_rowid
+			JOIN TrackLines n ON n.transcript_id = c.transcript_id AND n.lang = c.lang AND n.pos BETWEEN p.pos - ? AND p.pos + ?
+			ORDER BY c.transcript_id, c.start_ms, c.cue_index, c.line_rowid, line_offset != 0, line_offset;
 		`)
+		}
+
+		contextLimit := queryData.ContextLimit
+		if contextLimit <= 0 {
+			contextLimit = defaultContextLimit
+		}
 
 		finalContextQuery := fmt.Sprintf(contextQuery.String(), inQuery)
 
 		// --- Build the arguments ---
 		contextSqlArgs := make([]any, 0, 2+len(idArgs))
-		contextSqlArgs = append(contextSqlArgs, ftsQuery)  // FTS query text
-		contextSqlArgs = append(contextSqlArgs, idArgs...) // Transcript IDs
-		if queryData.MatchWholeWord {
-			contextSqlArgs = append(contextSqlArgs, wholeWordRegexPattern) // Regexp pattern (optional)
+		contextSqlArgs = append(contextSqlArgs, idArgs...)         // Transcript IDs
+		contextSqlArgs = append(contextSqlArgs, searchArgs...)     // Search text, whole word and NEAR checks
+		contextSqlArgs = append(contextSqlArgs, lineFilterArgs...) // Line filters (optional)
+		contextSqlArgs = append(contextSqlArgs, queryData.ContextOffset, queryData.ContextOffset+contextLimit)
+		if queryData.Before != 0 || queryData.After != 0 {
+			contextSqlArgs = append(contextSqlArgs, queryData.Before, queryData.After)
 		}
 
 		contextRows, err := a.db.QueryContext(ctx, finalContextQuery, contextSqlArgs...)
@@ -429,19 +1795,40 @@ func (a *App) queryTranscripts(ctx context.Context, queryData QueryData) (Transc
 		}
 		defer contextRows.Close()
 
+		var lastContext *SearchContext // Neighbouring lines follow their context
 		for contextRows.Next() {
 			var transcriptID string
+			var lineOffset int
 			var context SearchContext // Use SearchContext directly
 
 			// --- Scan only startTime and the original text (Line) ---
-			if err := contextRows.Scan(&transcriptID, &context.StartTime, &context.Line); err != nil {
+			if err := contextRows.Scan(&transcriptID, &lineOffset, &context.StartTime, &context.Lang, &context.Speaker, &context.Line); err != nil {
 				return TranscriptSearchOutput{}, fmt.Errorf("failed to scan context row: %w", err)
 			}
+			if lineOffset != 0 {
+				if lastContext != nil {
+					line := ContextLine{StartTime: context.StartTime, Speaker: context.Speaker, Line: context.Line}
+					if lineOffset < 0 {
+						lastContext.Before = append(lastContext.Before, line)
+					} else {
+						lastContext.After = append(lastContext.After, line)
+					}
+				}
+				continue
+			}
+
+			// Highlight the matches, in the snippet if there is one.
+			matches := matchRanges(context.Line, searchRe, countColumn == "tl.text")
+			if queryData.Snippet {
+				context.Line = matchSnippet(context.Line, matches)
+				matches = matchRanges(context.Line, searchRe, countColumn == "tl.text")
+			}
+			context.Highlights = highlightRanges(context.Line, matches)
 
 			// Find the corresponding transcript object
 			if res, ok := resultsMap[transcriptID]; ok {
-				// --- No snippet creation needed ---
 				res.Contexts = append(res.Contexts, context)
+				lastContext = &res.Contexts[len(res.Contexts)-1]
 			}
 		}
 		// Check for errors during row iteration
@@ -450,39 +1837,119 @@ func (a *App) queryTranscripts(ctx context.Context, queryData QueryData) (Transc
 		}
 	}
 
-	// If we performed a strict whole word search, remove transcripts
-	// that had metadata matches but no actual whole word context matches.
-	if queryData.MatchWholeWord && queryData.SearchText != "" {
-		filteredResultsList := make([]*TranscriptSearch, 0, len(resultsList)) // Pre-allocate capacity
-		for _, res := range resultsList {
-			// Keep the transcript only if it has contexts
-			if len(res.Contexts) > 0 {
-				filteredResultsList = append(filteredResultsList, res)
-			}
+	return output, nil
+}
+
+// Searches one track of a transcript, returning its matching lines in order with the IDs they have in GET /transcript/:id.
+// The track is the first of queryData.Langs, or the default track.
+// notFound and err are used to differentiate between a 400 and 500 error.
+// notFound && err -> 404 | !notFound && err -> 500 | !notFound && !err -> 200
+func (a *App) searchTranscriptLines(ctx context.Context, id string, queryData QueryData) (output TranscriptMatchesOutput, notFound bool, err error) {
+	if queryData.SearchMode == SearchModeRegex {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, regexSearchTimeout)
+		defer cancel()
+		defer func() { err = searchTimeoutError(ctx, err) }()
+	}
+
+	// The metadata lookup also enforces membership access.
+	if _, notFound, err := a.retrieveStreamMetadata(ctx, id); err != nil {
+		return TranscriptMatchesOutput{}, notFound, err
+	}
+
+	var lang string
+	if len(queryData.Langs) > 0 {
+		lang = queryData.Langs[0]
+	}
+	output = TranscriptMatchesOutput{ID: id, Matches: []LineMatch{}}
+	output.Lang, _, notFound, err = resolveTrack(ctx, a.db, id, lang)
+	if err != nil {
+		return TranscriptMatchesOutput{}, notFound, err
+	}
+
+	search, err := a.parseQueryDataSearch(ctx, queryData)
+	if err != nil {
+		return TranscriptMatchesOutput{}, false, err
+	}
+	searchRe, countColumn, err := a.getCountRegex(queryData, search)
+	if err != nil {
+		return TranscriptMatchesOutput{}, false, fmt.Errorf("failed to compile regex: %w", err)
+	}
+
+	var joins, filters strings.Builder
+	args := []any{id, output.Lang}
+	buildSearchQuery(&joins, &filters, &args, queryData, search)
+	if queryData.MatchWholeWord && queryData.SearchMode != SearchModeRegex {
+		search.buildWholeWordQuery(&filters, &args)
+	}
+	lineQueryData := queryData
+	lineQueryData.Langs = []string{output.Lang} // The track is already selected
+	buildLineFilterQuery(&filters, &args, lineQueryData)
+
+	query := `
+		SELECT tl.line_id, tl.start_time, tl.start_ms, tl.text
+		FROM transcript_lines tl` + joins.String() + `
+		WHERE tl.transcript_id = ? AND tl.lang = ?` + filters.String() + `
+		ORDER BY tl.start_ms, tl.cue_index`
+
+	rows, err := a.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return TranscriptMatchesOutput{}, false, fmt.Errorf("failed to query matching lines: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var lineID int64
+		var text string
+		var match LineMatch
+		if err := rows.Scan(&lineID, &match.Start, &match.StartMs, &text); err != nil {
+			return TranscriptMatchesOutput{}, false, fmt.Errorf("failed to scan matching line: %w", err)
 		}
-		resultsList = filteredResultsList // Replace the original list with the filtered one
+		match.ID = strconv.FormatInt(lineID, 10)
+		match.Highlights = highlightRanges(text, matchRanges(text, searchRe, countColumn == "tl.text"))
+		output.Matches = append(output.Matches, match)
+	}
+	if err := rows.Err(); err != nil {
+		return TranscriptMatchesOutput{}, false, fmt.Errorf("error during rows iteration: %w", err)
 	}
 
-	return TranscriptSearchOutput{Result: resultsList}, nil
+	output.Total = len(output.Matches)
+	return output, false, nil
 }
 
+// Restricts lines (aliased tl) to the default track of their transcript, which is the first track uploaded.
+// Searches and graphs use the default track when no lang is given, so translations of a line are not counted twice.
+const defaultTrackFilter = `
+	AND tl.lang = (SELECT r.lang FROM transcript_revisions r WHERE r.transcript_id = tl.transcript_id ORDER BY r.revision LIMIT 1)`
+
 // Retrieves a list of points of where the query matches in the transcript for the given ID.
 // x-axis: time "hh:mm:ss" | y-axis: number of matches
-func (a *App) querySingleGraph(ctx context.Context, id string, queryData QueryData) (GraphOutput, error) {
-	searchRe, err := a.getRegex(queryData.SearchText, queryData.MatchWholeWord)
+func (a *App) querySingleGraph(ctx context.Context, id string, queryData QueryData) (output GraphOutput, err error) {
+	if queryData.SearchMode == SearchModeRegex {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, regexSearchTimeout)
+		defer cancel()
+		defer func() { err = searchTimeoutError(ctx, err) }()
+	}
+
+	search, err := a.parseQueryDataSearch(ctx, queryData)
+	if err != nil {
+		return GraphOutput{}, err
+	}
+	searchRe, countColumn, err := a.getCountRegex(queryData, search)
 	if err != nil {
 		return GraphOutput{}, fmt.Errorf("failed to compile regex: %w", err)
 	}
-	ftsQuery := buildFTSQuery(queryData.SearchText)
+
+	var joins, filters strings.Builder
+	args := []any{id}
+	buildSearchQuery(&joins, &filters, &args, queryData, search)
 
 	query := `
-		SELECT tl.start_time, tl.clean_text
+		SELECT tl.start_time, tl.start_ms, ` + countColumn + `
 		FROM transcript_lines tl
-		JOIN transcripts t ON tl.transcript_id = t.id
-		JOIN transcript_search ts ON tl.rowid = ts.rowid
-		WHERE tl.transcript_id = ? AND ts.clean_text MATCH ?
-	`
-	args := []any{id, ftsQuery}
+		JOIN transcripts t ON tl.transcript_id = t.id` + joins.String() + `
+		WHERE tl.transcript_id = ?` + filters.String()
 
 	// Add restriction
 	query += " AND (t.stream_type != 'Members'"
@@ -492,7 +1959,11 @@ func (a *App) querySingleGraph(ctx context.Context, id string, queryData QueryDa
 	}
 	query += ")"
 
-	query += " ORDER BY tl.start_time"
+	var lineFilters strings.Builder
+	buildLineFilterQuery(&lineFilters, &args, queryData)
+	query += lineFilters.String()
+
+	query += " ORDER BY tl.start_ms, tl.cue_index"
 
 	rows, err := a.db.QueryContext(ctx, query, args...) // Use args...
 	if err != nil {
@@ -500,39 +1971,52 @@ func (a *App) querySingleGraph(ctx context.Context, id string, queryData QueryDa
 	}
 	defer rows.Close()
 
-	timeCounts := make(map[string]int)
+	// Rows are already in numeric time order, so points are appended in order as each new timestamp is seen.
+	graphData := make([]GraphDataPoint, 0)
+	timeIndex := make(map[string]int)
 	for rows.Next() {
-		var startTime, cleanText string
-		if err := rows.Scan(&startTime, &cleanText); err != nil {
+		var startTime, text string
+		var startMs int64
+		if err := rows.Scan(&startTime, &startMs, &text); err != nil {
 			return GraphOutput{}, fmt.Errorf("failed to scan graph data row: %w", err)
 		}
-		matches := searchRe.FindAllStringIndex(cleanText, -1)
-		if len(matches) > 0 {
-			timeCounts[startTime] += len(matches)
+		matches := searchRe.FindAllStringIndex(text, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		if i, ok := timeIndex[startTime]; ok {
+			graphData[i].Y += len(matches)
+		} else {
+			timeIndex[startTime] = len(graphData)
+			graphData = append(graphData, GraphDataPoint{X: startTime, Y: len(matches)})
 		}
 	}
-
-	graphData := make([]GraphDataPoint, 0, len(timeCounts))
-	for timestamp, count := range timeCounts {
-		graphData = append(graphData, GraphDataPoint{X: timestamp, Y: count})
+	if err := rows.Err(); err != nil {
+		return GraphOutput{}, fmt.Errorf("error during rows iteration: %w", err)
 	}
 
-	sort.Slice(graphData, func(i, j int) bool {
-		return graphData[i].X < graphData[j].X
-	})
-
 	return GraphOutput{Result: graphData}, nil
 }
 
 // Retrieves a list of points of where the query matches in the transcript for all transcripts.
 // x-axis: date "YYYY-MM-DD" | y-axis: number of matches for that day
-func (a *App) queryAllGraphs(ctx context.Context, queryData QueryData) (GraphOutput, error) {
+func (a *App) queryAllGraphs(ctx context.Context, queryData QueryData) (output GraphOutput, err error) {
+	if queryData.SearchMode == SearchModeRegex {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, regexSearchTimeout)
+		defer cancel()
+		defer func() { err = searchTimeoutError(ctx, err) }()
+	}
+
 	// --- Get Regex for counting ---
-	searchRe, err := a.getRegex(queryData.SearchText, queryData.MatchWholeWord)
+	search, err := a.parseQueryDataSearch(ctx, queryData)
+	if err != nil {
+		return GraphOutput{}, err
+	}
+	searchRe, countColumn, err := a.getCountRegex(queryData, search)
 	if err != nil {
 		return GraphOutput{}, fmt.Errorf("failed to compile regex: %w", err)
 	}
-	ftsQuery := buildFTSQuery(queryData.SearchText)
 
 	// --- Filter Criteria (same as /transcripts) ---
 	var qParams strings.Builder
@@ -542,15 +2026,13 @@ func (a *App) queryAllGraphs(ctx context.Context, queryData QueryData) (GraphOut
 	// --- Build the main query ---
 	var query strings.Builder
 	query.WriteString(`
-		SELECT t.date, tl.clean_text
+		SELECT t.date, ` + countColumn + `
 		FROM transcripts t
-		JOIN transcript_lines tl ON t.id = tl.transcript_id
-		JOIN transcript_search ts ON tl.rowid = ts.rowid
-	`)
+		JOIN transcript_lines tl ON t.id = tl.transcript_id`)
 
 	// Add the search text filter
-	qParams.WriteString(" AND ts.clean_text MATCH ?")
-	sqlArgs = append(sqlArgs, ftsQuery)
+	buildSearchQuery(&query, &qParams, &sqlArgs, queryData, search)
+	buildLineFilterQuery(&qParams, &sqlArgs, queryData)
 
 	// Add all other filters (streamer, from, to, etc.)
 	query.WriteString(qParams.String())
@@ -565,16 +2047,19 @@ func (a *App) queryAllGraphs(ctx context.Context, queryData QueryData) (GraphOut
 	// --- Aggregate counts by date ---
 	dateCounts := make(map[string]int)
 	for rows.Next() {
-		var date, cleanText string
-		if err := rows.Scan(&date, &cleanText); err != nil {
+		var date, text string
+		if err := rows.Scan(&date, &text); err != nil {
 			return GraphOutput{}, fmt.Errorf("failed to scan graph data row: %w", err)
 		}
 		// Use the regex (which respects matchWholeWord) to count
-		matches := searchRe.FindAllStringIndex(cleanText, -1)
+		matches := searchRe.FindAllStringIndex(text, -1)
 		if len(matches) > 0 {
 			dateCounts[date] += len(matches)
 		}
 	}
+	if err := rows.Err(); err != nil {
+		return GraphOutput{}, fmt.Errorf("error during rows iteration: %w", err)
+	}
 
 	// --- Format and return data ---
 	graphData := make([]GraphDataPoint, 0, len(dateCounts))
@@ -2,8 +2,15 @@ package internal
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 )
 
 // seedDBForQueryTests inserts a varied set of transcripts for testing filters.
@@ -53,7 +60,7 @@ func seedDBForQueryTests(t *testing.T, app *App) {
 	}
 
 	for _, in := range inputs {
-		if err := app.insertTranscript(ctx, &in); err != nil {
+		if _, err := app.insertTranscript(ctx, &in); err != nil {
 			t.Fatalf("Failed to insert %s: %v", in.ID, err)
 		}
 	}
@@ -65,7 +72,7 @@ func TestDatabase_InitAndInsert(t *testing.T) {
 	ctx := context.Background()
 
 	// Initial check
-	streams, err := app.retrieveAllStreams(ctx)
+	streams, err := app.retrieveAllStreams(ctx, QueryData{})
 	if err != nil {
 		t.Fatalf("retrieveAllStreams failed: %v", err)
 	}
@@ -82,12 +89,12 @@ func TestDatabase_InitAndInsert(t *testing.T) {
 		StreamType:    "Stream",
 		SrtTranscript: "1\n00:00:01,000 --> 00:00:05,000\nHello\n\n",
 	}
-	if err := app.insertTranscript(ctx, &input); err != nil {
+	if _, err := app.insertTranscript(ctx, &input); err != nil {
 		t.Fatalf("insertTranscript failed: %v", err)
 	}
 
 	// Retrieve
-	out, err := app.retrieveAllStreams(ctx)
+	out, err := app.retrieveAllStreams(ctx, QueryData{})
 	if err != nil {
 		t.Fatalf("retrieveAllStreams failed after insert: %v", err)
 	}
@@ -120,19 +127,34 @@ func TestDatabase_QueryTranscripts(t *testing.T) {
 		},
 		{
 			name:        "Filter by StreamerB",
-			query:       QueryData{Streamer: "StreamerB"},
+			query:       QueryData{Streamers: []string{"StreamerB"}},
 			expectedIDs: []string{"v3"},
 		},
 		{
 			name:        "Filter by StreamerB (Case Insensitive)",
-			query:       QueryData{Streamer: "streamerb"},
+			query:       QueryData{Streamers: []string{"streamerb"}},
 			expectedIDs: []string{},
 		},
 		{
 			name:        "Filter by StreamerA",
-			query:       QueryData{Streamer: "StreamerA"},
+			query:       QueryData{Streamers: []string{"StreamerA"}},
 			expectedIDs: []string{"v2", "v1", "v4"},
 		},
+		{
+			name:        "Filter by several Streamers",
+			query:       QueryData{Streamers: []string{"StreamerB", "StreamerC"}},
+			expectedIDs: []string{"v3", "v5"},
+		},
+		{
+			name:        "Exclude Streamer",
+			query:       QueryData{ExcludeStreamers: []string{"StreamerA", "StreamerC"}},
+			expectedIDs: []string{"v3"},
+		},
+		{
+			name:        "Exclude StreamType",
+			query:       QueryData{Streamers: []string{"StreamerA"}, ExcludeStreamTypes: []string{"VOD", "Other"}},
+			expectedIDs: []string{"v1"},
+		},
 		{
 			name:        "Filter by StreamType VOD",
 			query:       QueryData{StreamTypes: []string{"VOD"}},
@@ -165,19 +187,34 @@ func TestDatabase_QueryTranscripts(t *testing.T) {
 		},
 		{
 			name:        "Filter by Title",
-			query:       QueryData{StreamTitle: "Unique"},
+			query:       QueryData{StreamTitles: []string{"Unique"}},
 			expectedIDs: []string{"v5"},
 		},
 		{
 			name:        "Filter by Title (Case Insensitive)",
-			query:       QueryData{StreamTitle: "unique"},
+			query:       QueryData{StreamTitles: []string{"unique"}},
 			expectedIDs: []string{"v5"},
 		},
 		{
 			name:        "Filter by Title (No Match)",
-			query:       QueryData{StreamTitle: "Invalid"},
+			query:       QueryData{StreamTitles: []string{"Invalid"}},
 			expectedIDs: []string{},
 		},
+		{
+			name:        "Filter by several Titles",
+			query:       QueryData{StreamTitles: []string{"unique", "vod"}},
+			expectedIDs: []string{"v2", "v5"},
+		},
+		{
+			name:        "Exclude Titles",
+			query:       QueryData{ExcludeTitles: []string{"first", "UNIQUE"}},
+			expectedIDs: []string{"v2", "v3", "v4"},
+		},
+		{
+			name:        "Exclude Title with Search Text",
+			query:       QueryData{SearchText: "hello", ExcludeTitles: []string{"StreamerB"}},
+			expectedIDs: []string{"v1"},
+		},
 		{
 			name:        "Search Text - Partial",
 			query:       QueryData{SearchText: "Hel"},
@@ -313,6 +350,108 @@ func TestDatabase_GraphQueries(t *testing.T) {
 			t.Errorf("Expected second point to be 2023-01-15, got %s", res.Result[1].X)
 		}
 	})
+
+	// 3. Exclusions apply to the graphs like to the search.
+	t.Run("AllGraphsExcluded", func(t *testing.T) {
+		q := QueryData{SearchText: "Hello", ExcludeStreamers: []string{"StreamerB"}}
+		res, err := app.queryAllGraphs(ctx, q)
+		if err != nil {
+			t.Fatalf("queryAllGraphs failed: %v", err)
+		}
+		if len(res.Result) != 1 || res.Result[0].X != "2023-01-01" {
+			t.Errorf("Expected only 2023-01-01, got %v", res.Result)
+		}
+	})
+}
+
+func TestDatabase_RetrieveAllStreams_Filters(t *testing.T) {
+	app := setupTestApp(t)
+	seedDBForQueryTests(t, app)
+	defer app.db.Close()
+	ctx := context.Background()
+
+	members := TranscriptInput{ID: "m1", Streamer: "StreamerB", Date: "2023-04-01", StreamType: "Members", StreamTitle: "Members Karaoke"}
+	if _, err := app.insertTranscript(ctx, &members); err != nil {
+		t.Fatalf("insertTranscript failed: %v", err)
+	}
+
+	tests := []struct {
+		query QueryData
+		want  []string
+	}{
+		{QueryData{FromDate: "2023-01-02"}, []string{"m1", "v4", "v2", "v3"}},
+		{QueryData{Streamers: []string{"StreamerB"}}, []string{"m1", "v3"}}, // Members metadata is public
+		{QueryData{Streamers: []string{"StreamerB"}, ExcludeTitles: []string{"karaoke"}}, []string{"v3"}},
+		{QueryData{ExcludeStreamTypes: []string{"Stream", "Members"}}, []string{"v4", "v2"}},
+	}
+	for _, tt := range tests {
+		streams, err := app.retrieveAllStreams(ctx, tt.query)
+		if err != nil {
+			t.Fatalf("retrieveAllStreams failed: %v", err)
+		}
+		var got []string
+		for _, s := range streams {
+			got = append(got, s.ID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("retrieveAllStreams(%+v) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestDatabase_SearchFacets(t *testing.T) {
+	app := setupTestApp(t)
+	seedDBForQueryTests(t, app)
+	defer app.db.Close()
+	ctx := context.Background()
+
+	members := TranscriptInput{ID: "m1", Streamer: "StreamerB", Date: "2024-02-01", StreamType: "Members",
+		SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nHello members\n\n2\n00:00:03,000 --> 00:00:04,000\nhello again\n\n"}
+	if _, err := app.insertTranscript(ctx, &members); err != nil {
+		t.Fatalf("insertTranscript failed: %v", err)
+	}
+
+	facets := []string{SearchFacetStreamer, SearchFacetStreamType, SearchFacetYear, SearchFacetMonth}
+	res, err := app.queryTranscripts(ctx, QueryData{SearchText: "hello", Facets: facets, Limit: 1})
+	if err != nil {
+		t.Fatalf("queryTranscripts failed: %v", err)
+	}
+	want := map[string][]FacetCount{
+		SearchFacetStreamer:   {{Value: "StreamerA", Transcripts: 1, Matches: 1}, {Value: "StreamerB", Transcripts: 1, Matches: 1}},
+		SearchFacetStreamType: {{Value: "Stream", Transcripts: 2, Matches: 2}},
+		SearchFacetYear:       {{Value: "2023", Transcripts: 2, Matches: 2}},
+		SearchFacetMonth:      {{Value: "2023-01", Transcripts: 2, Matches: 2}},
+	}
+	for facet, counts := range want {
+		if !slices.Equal(res.Facets[facet], counts) {
+			t.Errorf("Facet %s = %+v, want %+v", facet, res.Facets[facet], counts)
+		}
+	}
+
+	// Members transcripts are counted with a key for their channel, and values with the most matches come first.
+	res, err = app.queryTranscripts(ctx, QueryData{SearchText: "hello", Facets: []string{SearchFacetStreamer, SearchFacetYear}, AuthorizedChannel: "StreamerB"})
+	if err != nil {
+		t.Fatalf("queryTranscripts failed: %v", err)
+	}
+	if want := []FacetCount{{Value: "StreamerB", Transcripts: 2, Matches: 3}, {Value: "StreamerA", Transcripts: 1, Matches: 1}}; !slices.Equal(res.Facets[SearchFacetStreamer], want) {
+		t.Errorf("Streamer facet = %+v, want %+v", res.Facets[SearchFacetStreamer], want)
+	}
+	if want := []FacetCount{{Value: "2023", Transcripts: 2, Matches: 2}, {Value: "2024", Transcripts: 1, Matches: 2}}; !slices.Equal(res.Facets[SearchFacetYear], want) {
+		t.Errorf("Year facet = %+v, want %+v", res.Facets[SearchFacetYear], want)
+	}
+
+	// Without search text, the filtered transcripts are counted.
+	res, err = app.queryTranscripts(ctx, QueryData{Facets: []string{SearchFacetStreamType}, ExcludeStreamers: []string{"StreamerC"}})
+	if err != nil {
+		t.Fatalf("queryTranscripts failed: %v", err)
+	}
+	if want := []FacetCount{{Value: "Stream", Transcripts: 2}, {Value: "Other", Transcripts: 1}, {Value: "VOD", Transcripts: 1}}; !slices.Equal(res.Facets[SearchFacetStreamType], want) {
+		t.Errorf("Stream type facet = %+v, want %+v", res.Facets[SearchFacetStreamType], want)
+	}
+
+	if res, err := app.queryTranscripts(ctx, QueryData{SearchText: "hello"}); err != nil || res.Facets != nil {
+		t.Errorf("Expected no facets unless requested, got %+v, %v", res.Facets, err)
+	}
 }
 
 func TestDatabase_RetrieveTranscript(t *testing.T) {
@@ -328,7 +467,7 @@ func TestDatabase_RetrieveTranscript(t *testing.T) {
 		StreamType:    "Stream",
 		SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nHello\n\n2\n00:00:03,000 --> 00:00:04,000\nWorld\n\n",
 	}
-	if err := app.insertTranscript(ctx, &ts); err != nil {
+	if _, err := app.insertTranscript(ctx, &ts); err != nil {
 		t.Fatalf("Failed to insert transcript: %v", err)
 	}
 
@@ -382,7 +521,7 @@ func TestDatabase_RetrieveTranscript_ContentOrder(t *testing.T) {
 		SrtTranscript: srt,
 	}
 
-	if err := app.insertTranscript(ctx, &input); err != nil {
+	if _, err := app.insertTranscript(ctx, &input); err != nil {
 		t.Fatalf("Failed to insert transcript: %v", err)
 	}
 
@@ -413,6 +552,115 @@ func TestDatabase_RetrieveTranscript_ContentOrder(t *testing.T) {
 	}
 }
 
+func TestDatabase_RetrieveTranscript_NumericOrder(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	ctx := context.Background()
+
+	// "100:00:00" sorts before "99:59:59" as a string, and single-digit hours are not zero padded.
+	// The two "00:00:05" lines share a start time and must keep their upload order.
+	srt := "1\n100:00:00,000 --> 100:00:01,000\nafter one hundred hours\n\n" +
+		"2\n99:59:59,000 --> 100:00:00,000\nbefore one hundred hours\n\n" +
+		"3\n9:00:00,000 --> 9:00:01,000\nnine hours\n\n" +
+		"4\n00:00:05,000 --> 00:00:06,000\ntie first\n\n" +
+		"5\n00:00:05,000 --> 00:00:06,000\ntie second\n\n"
+	input := TranscriptInput{ID: "long", Streamer: "Tester", Date: "2023-01-01", StreamType: "Stream", SrtTranscript: srt}
+	if _, err := app.insertTranscript(ctx, &input); err != nil {
+		t.Fatalf("Failed to insert transcript: %v", err)
+	}
+
+	wantTexts := []string{"tie first", "tie second", "nine hours", "before one hundred hours", "after one hundred hours"}
+
+	// 1. retrieveTranscript
+	got, _, err := app.retrieveTranscript(ctx, "long")
+	if err != nil {
+		t.Fatalf("Failed to retrieve transcript: %v", err)
+	}
+	if len(got.TranscriptLines) != len(wantTexts) {
+		t.Fatalf("Expected %d lines, got %d", len(wantTexts), len(got.TranscriptLines))
+	}
+	for i, text := range wantTexts {
+		if got.TranscriptLines[i].Text != text {
+			t.Errorf("Line %d: expected '%s', got '%s'", i, text, got.TranscriptLines[i].Text)
+		}
+	}
+
+	// 2. queryTranscripts contexts
+	res, err := app.queryTranscripts(ctx, QueryData{SearchText: "hours"})
+	if err != nil {
+		t.Fatalf("queryTranscripts failed: %v", err)
+	}
+	if len(res.Result) != 1 || len(res.Result[0].Contexts) != 3 {
+		t.Fatalf("Expected 1 result with 3 contexts, got %+v", res.Result)
+	}
+	wantStarts := []string{"09:00:00", "99:59:59", "100:00:00"}
+	for i, start := range wantStarts {
+		if res.Result[0].Contexts[i].StartTime != start {
+			t.Errorf("Context %d: expected start %s, got %s", i, start, res.Result[0].Contexts[i].StartTime)
+		}
+	}
+
+	// 3. querySingleGraph
+	graph, err := app.querySingleGraph(ctx, "long", QueryData{SearchText: "hours"})
+	if err != nil {
+		t.Fatalf("querySingleGraph failed: %v", err)
+	}
+	if len(graph.Result) != 3 {
+		t.Fatalf("Expected 3 points, got %d", len(graph.Result))
+	}
+	for i, start := range wantStarts {
+		if graph.Result[i].X != start {
+			t.Errorf("Point %d: expected %s, got %s", i, start, graph.Result[i].X)
+		}
+	}
+}
+
+func TestDatabase_RetrieveTranscriptWindow(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	ctx := context.Background()
+
+	// The two "00:00:02,900" lines share a start time and must keep their upload order.
+	srt := "1\n00:00:06,000 --> 00:00:07,000\nfive\n\n" +
+		"2\n00:00:01,000 --> 00:00:02,000\nzero\n\n" +
+		"3\n00:00:02,500 --> 00:00:03,000\none\n\n" +
+		"4\n00:00:02,900 --> 00:00:03,500\ntwo\n\n" +
+		"5\n00:00:02,900 --> 00:00:03,800\nthree\n\n" +
+		"6\n00:00:04,000 --> 00:00:05,000\nfour\n\n"
+	input := TranscriptInput{ID: "window", Streamer: "Tester", Date: "2023-01-01", StreamType: "Stream", SrtTranscript: srt}
+	if _, err := app.insertTranscript(ctx, &input); err != nil {
+		t.Fatalf("Failed to insert transcript: %v", err)
+	}
+	full, _, err := app.retrieveTranscript(ctx, "window")
+	if err != nil {
+		t.Fatalf("Failed to retrieve transcript: %v", err)
+	}
+
+	ms := func(v int64) *int64 { return &v }
+	windows := []TranscriptWindow{
+		{From: ms(2000)},
+		{To: ms(2000)},
+		{From: ms(3000), To: ms(4000)},
+		{From: ms(7000)},
+		{Around: ms(2900), Lines: 1},
+		{Around: ms(4000), Lines: 2},
+		{Around: ms(0), Lines: 2},
+		{Around: ms(60000), Lines: 0},
+	}
+	for _, window := range windows {
+		got, _, err := app.retrieveTranscriptWindow(ctx, "window", "", window)
+		if err != nil {
+			t.Fatalf("retrieveTranscriptWindow(%+v) failed: %v", window, err)
+		}
+		if got.TotalLines != full.TotalLines {
+			t.Errorf("%+v: expected %d total lines, got %d", window, full.TotalLines, got.TotalLines)
+		}
+		if want := window.slice(full.TranscriptLines); !slices.Equal(got.TranscriptLines, want) {
+			t.Errorf("%+v: expected lines %+v, got %+v", window, want, got.TranscriptLines)
+		}
+	}
+}
+
 func TestDatabase_RetrieveTranscript_FullContentVerification(t *testing.T) {
 	app := setupTestApp(t)
 	defer app.db.Close()
@@ -427,7 +675,7 @@ func TestDatabase_RetrieveTranscript_FullContentVerification(t *testing.T) {
 		SrtTranscript: "1\n00:00:10,000 --> 00:00:15,000\nContent A\n\n2\n00:00:20,000 --> 00:00:25,000\nContent B\n\n",
 	}
 
-	if err := app.insertTranscript(ctx, &input); err != nil {
+	if _, err := app.insertTranscript(ctx, &input); err != nil {
 		t.Fatalf("Failed to insert transcript: %v", err)
 	}
 
@@ -465,6 +713,9 @@ func TestDatabase_RetrieveTranscript_FullContentVerification(t *testing.T) {
 	if line1.Text != "Content A" {
 		t.Errorf("Line 1 Text mismatch: got %s, want Content A", line1.Text)
 	}
+	if line1.StartMs != 10000 || line1.EndMs != 15000 {
+		t.Errorf("Line 1 times mismatch: got %d-%d, want 10000-15000", line1.StartMs, line1.EndMs)
+	}
 	if line1.ID == "" {
 		t.Error("Line 1 ID should not be empty")
 	}
@@ -473,6 +724,9 @@ func TestDatabase_RetrieveTranscript_FullContentVerification(t *testing.T) {
 	if line2.Start != "00:00:20" {
 		t.Errorf("Line 2 Start mismatch: got %s, want 00:00:20", line2.Start)
 	}
+	if line2.StartMs != 20000 || line2.EndMs != 25000 {
+		t.Errorf("Line 2 times mismatch: got %d-%d, want 20000-25000", line2.StartMs, line2.EndMs)
+	}
 	if line2.Text != "Content B" {
 		t.Errorf("Line 2 Text mismatch: got %s, want Content B", line2.Text)
 	}
@@ -492,12 +746,12 @@ func TestDatabase_RetrieveAllStreams_ContentAndOrder(t *testing.T) {
 	}
 
 	for _, in := range inputs {
-		if err := app.insertTranscript(ctx, &in); err != nil {
+		if _, err := app.insertTranscript(ctx, &in); err != nil {
 			t.Fatalf("Failed to insert %s: %v", in.ID, err)
 		}
 	}
 
-	got, err := app.retrieveAllStreams(ctx)
+	got, err := app.retrieveAllStreams(ctx, QueryData{})
 	if err != nil {
 		t.Fatalf("retrieveAllStreams failed: %v", err)
 	}
@@ -544,7 +798,7 @@ func TestDatabase_QueryTranscripts_ContentAndOrder(t *testing.T) {
 	}
 
 	// 1. Test Metadata Order (Date DESC)
-	q := QueryData{Streamer: "A"} // No search text
+	q := QueryData{Streamers: []string{"A"}} // No search text
 	res, err := app.queryTranscripts(ctx, q)
 	if err != nil {
 		t.Fatalf("queryTranscripts failed: %v", err)
@@ -572,7 +826,7 @@ func TestDatabase_QueryTranscripts_ContentAndOrder(t *testing.T) {
 	}
 	app.insertTranscript(ctx, &multiMatchInput)
 
-	q2 := QueryData{Streamer: "B", SearchText: "match"}
+	q2 := QueryData{Streamers: []string{"B"}, SearchText: "match"}
 	res2, err := app.queryTranscripts(ctx, q2)
 	if err != nil {
 		t.Fatalf("queryTranscripts (search) failed: %v", err)
@@ -656,3 +910,1351 @@ func TestDatabase_GraphQueries_ContentAndOrder(t *testing.T) {
 		t.Errorf("queryAllGraphs: expected second point at 2023-01-02, got %s", allRes.Result[1].X)
 	}
 }
+
+func TestDatabase_MigrateLineTimes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	dbConfig := DatabaseConfig{JournalMode: "MEMORY", Synchronous: "OFF"}
+
+	// 1. Create a database using the schema from before start_ms/end_ms existed.
+	legacy, err := sql.Open("sqlite3_with_regex", path)
+	if err != nil {
+		t.Fatalf("Failed to open legacy DB: %v", err)
+	}
+	legacySchema := `
+	CREATE TABLE transcripts (id TEXT PRIMARY KEY, streamer TEXT, date TEXT, title TEXT, stream_type TEXT);
+	CREATE TABLE transcript_lines (
+		rowid INTEGER PRIMARY KEY,
+		transcript_id TEXT NOT NULL,
+		start_time TEXT,
+		text TEXT,
+		clean_text TEXT,
+		FOREIGN KEY(transcript_id) REFERENCES transcripts(id) ON DELETE CASCADE
+	);
+	CREATE VIRTUAL TABLE transcript_search USING fts5(
+		clean_text,
+		content='transcript_lines',
+		content_rowid='rowid',
+		tokenize = 'porter unicode61 remove_diacritics 2'
+	);
+	CREATE TRIGGER tl_ai AFTER INSERT ON transcript_lines BEGIN
+		INSERT INTO transcript_search(rowid, clean_text) VALUES (new.rowid, new.clean_text);
+	END;
+	CREATE TRIGGER tl_au AFTER UPDATE ON transcript_lines BEGIN
+		INSERT INTO transcript_search(transcript_search, rowid, clean_text) VALUES ('delete', old.rowid, old.clean_text);
+		INSERT INTO transcript_search(rowid, clean_text) VALUES (new.rowid, new.clean_text);
+	END;
+	INSERT INTO transcripts VALUES ('old', 'Streamer', '2023-01-01', 'Old Stream', 'Stream');
+	INSERT INTO transcript_lines (transcript_id, start_time, text, clean_text) VALUES
+		('old', '01:00:05', 'Last line', 'last line'),
+		('old', '00:00:01', 'First line', 'first line'),
+		('old', '00:01:30', 'Middle line', 'middle line');
+	`
+	if _, err := legacy.Exec(legacySchema); err != nil {
+		t.Fatalf("Failed to create legacy schema: %v", err)
+	}
+	legacy.Close()
+
+	// 2. Opening it with InitDB should migrate the existing rows.
+	db, err := InitDB(path, dbConfig)
+	if err != nil {
+		t.Fatalf("InitDB failed to migrate legacy DB: %v", err)
+	}
+	app := NewApp(db, Config{}, "test", "0")
+
+	got, _, err := app.retrieveTranscript(context.Background(), "old")
+	if err != nil {
+		t.Fatalf("Failed to retrieve migrated transcript: %v", err)
+	}
+	want := []TranscriptLine{
+		{ID: "0", Start: "00:00:01", StartMs: 1000, EndMs: 90000, Text: "First line"},
+		{ID: "1", Start: "00:01:30", StartMs: 90000, EndMs: 3605000, Text: "Middle line"},
+		{ID: "2", Start: "01:00:05", StartMs: 3605000, EndMs: 3605000, Text: "Last line"},
+	}
+	if len(got.TranscriptLines) != len(want) {
+		t.Fatalf("Expected %d lines, got %d", len(want), len(got.TranscriptLines))
+	}
+	for i := range want {
+		if got.TranscriptLines[i] != want[i] {
+			t.Errorf("Line %d: got %+v, want %+v", i, got.TranscriptLines[i], want[i])
+		}
+	}
+
+	// Search still works on migrated rows
+	res, err := app.queryTranscripts(context.Background(), QueryData{SearchText: "middle"})
+	if err != nil {
+		t.Fatalf("queryTranscripts failed: %v", err)
+	}
+	if len(res.Result) != 1 || len(res.Result[0].Contexts) != 1 {
+		t.Errorf("Expected 1 result with 1 context after migration, got %+v", res.Result)
+	}
+
+	// The search index is only updated when the clean text changes.
+	var trigger string
+	if err := app.db.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'trigger' AND name = 'tl_au'").Scan(&trigger); err != nil || !strings.Contains(trigger, "AFTER UPDATE OF clean_text") {
+		t.Errorf("Expected tl_au to be narrowed to clean_text, got %q, %v", trigger, err)
+	}
+
+	// The existing lines are kept as the first revision.
+	_, revLines, _, err := app.retrieveRevisionLines(context.Background(), "old", 1)
+	if err != nil {
+		t.Fatalf("Failed to retrieve backfilled revision: %v", err)
+	}
+	if !slices.Equal(revLines, want) {
+		t.Errorf("Backfilled revision: got %+v, want %+v", revLines, want)
+	}
+
+	// 3. Running InitDB again is a no-op.
+	app.db.Close()
+	db, err = InitDB(path, dbConfig)
+	if err != nil {
+		t.Fatalf("InitDB failed on already migrated DB: %v", err)
+	}
+	db.Close()
+}
+
+func TestDatabase_MigrateContentHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hash.db")
+	dbConfig := DatabaseConfig{JournalMode: "MEMORY", Synchronous: "OFF"}
+
+	// Databases from before tracks existed kept the content hash on the transcript.
+	db, err := InitDB(path, dbConfig)
+	if err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	if _, err := db.Exec("ALTER TABLE transcripts ADD COLUMN content_hash TEXT"); err != nil {
+		t.Fatalf("Failed to add content_hash: %v", err)
+	}
+	db.Close()
+
+	db, err = InitDB(path, dbConfig)
+	if err != nil {
+		t.Fatalf("InitDB failed to migrate: %v", err)
+	}
+	defer db.Close()
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('transcripts') WHERE name = 'content_hash'").Scan(&count); err != nil || count != 0 {
+		t.Errorf("Expected content_hash to be dropped, got %d, %v", count, err)
+	}
+}
+
+func TestDatabase_InsertTranscriptBatch(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	ctx := context.Background()
+
+	// Force the insert of one transcript in the batch to fail.
+	_, err := app.db.Exec("CREATE TRIGGER fail_boom BEFORE INSERT ON transcripts WHEN new.id = 'boom' BEGIN SELECT RAISE(ABORT, 'boom'); END;")
+	if err != nil {
+		t.Fatalf("Failed to create trigger: %v", err)
+	}
+
+	batch := []*TranscriptInput{
+		{ID: "b1", Streamer: "A", Date: "2023-01-01", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nfirst\n\n"},
+		{ID: "boom", Streamer: "A", Date: "2023-01-01", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nbroken\n\n"},
+		{ID: "b2", Streamer: "A", Date: "2023-01-02", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nsecond\n\n"},
+	}
+	statuses, itemErrs, err := app.insertTranscriptBatch(ctx, batch)
+	if err != nil {
+		t.Fatalf("insertTranscriptBatch failed: %v", err)
+	}
+	if len(itemErrs) != 3 || itemErrs[0] != nil || itemErrs[1] == nil || itemErrs[2] != nil {
+		t.Fatalf("Unexpected item errors: %v", itemErrs)
+	}
+	if statuses[0] != TranscriptStatusCreated || statuses[2] != TranscriptStatusCreated {
+		t.Errorf("Unexpected statuses: %v", statuses)
+	}
+
+	// The failed transcript is rolled back on its own, the rest are saved.
+	streams, err := app.retrieveAllStreams(ctx, QueryData{})
+	if err != nil {
+		t.Fatalf("retrieveAllStreams failed: %v", err)
+	}
+	if len(streams) != 2 {
+		t.Errorf("Expected 2 streams, got %d", len(streams))
+	}
+	lineCount := 0
+	app.db.QueryRow("SELECT COUNT(*) FROM transcript_lines").Scan(&lineCount)
+	if lineCount != 2 {
+		t.Errorf("Expected 2 transcript lines, got %d", lineCount)
+	}
+	res, err := app.queryTranscripts(ctx, QueryData{SearchText: "broken"})
+	if err != nil {
+		t.Fatalf("queryTranscripts failed: %v", err)
+	}
+	if len(res.Result) != 0 {
+		t.Errorf("Expected rolled back lines to be absent from search, got %d results", len(res.Result))
+	}
+}
+
+func TestDatabase_InsertTranscript_ContentHash(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	ctx := context.Background()
+
+	lineRowIDs := func() string {
+		var ids string
+		app.db.QueryRow("SELECT group_concat(rowid) FROM transcript_lines WHERE transcript_id = 'h1'").Scan(&ids)
+		return ids
+	}
+
+	input := TranscriptInput{ID: "h1", Streamer: "A", Date: "2023-01-01", StreamTitle: "Title", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nhello world\n\n"}
+	status, err := app.insertTranscript(ctx, &input)
+	if err != nil || status != TranscriptStatusCreated {
+		t.Fatalf("First insert: got %q, %v", status, err)
+	}
+	original := lineRowIDs()
+
+	// Identical upload does not touch the lines.
+	status, err = app.insertTranscript(ctx, &input)
+	if err != nil || status != TranscriptStatusUnchanged {
+		t.Fatalf("Identical insert: got %q, %v", status, err)
+	}
+	if got := lineRowIDs(); got != original {
+		t.Errorf("Lines were rewritten: %s -> %s", original, got)
+	}
+
+	// Metadata-only change updates the transcript row and keeps the lines.
+	input.StreamTitle = "Fixed Title"
+	input.StreamType = "Video"
+	status, err = app.insertTranscript(ctx, &input)
+	if err != nil || status != TranscriptStatusUpdated {
+		t.Fatalf("Metadata insert: got %q, %v", status, err)
+	}
+	if got := lineRowIDs(); got != original {
+		t.Errorf("Lines were rewritten: %s -> %s", original, got)
+	}
+	tr, _, err := app.retrieveTranscript(ctx, "h1")
+	if err != nil {
+		t.Fatalf("retrieveTranscript failed: %v", err)
+	}
+	if tr.StreamTitle != "Fixed Title" || tr.StreamType != "Video" {
+		t.Errorf("Metadata not updated: %+v", tr)
+	}
+	res, err := app.queryTranscripts(ctx, QueryData{SearchText: "hello"})
+	if err != nil || len(res.Result) != 1 || res.Result[0].Title != "Fixed Title" {
+		t.Errorf("Unexpected search result after metadata update: %+v, %v", res, err)
+	}
+
+	// Changed content rewrites the lines.
+	input.SrtTranscript = "1\n00:00:01,000 --> 00:00:02,000\ngoodbye world\n\n"
+	status, err = app.insertTranscript(ctx, &input)
+	if err != nil || status != TranscriptStatusCreated {
+		t.Fatalf("Changed insert: got %q, %v", status, err)
+	}
+	res, err = app.queryTranscripts(ctx, QueryData{SearchText: "hello"})
+	if err != nil || len(res.Result) != 0 {
+		t.Errorf("Old content still searchable: %+v, %v", res, err)
+	}
+
+	// Same text in another format is parsed again.
+	input.Format = TranscriptFormatVTT
+	input.VttTranscript = "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\ngoodbye world\n"
+	status, err = app.insertTranscript(ctx, &input)
+	if err != nil || status != TranscriptStatusCreated {
+		t.Fatalf("Format change insert: got %q, %v", status, err)
+	}
+}
+
+func TestDatabase_TranscriptRevisions(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	ctx := context.Background()
+
+	uploads := []TranscriptInput{
+		{ID: "r1", Streamer: "A", Date: "2023-01-01", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nhello there\n\n2\n00:00:03,000 --> 00:00:04,000\ngeneral kenobi\n\n"},
+		{ID: "r1", Streamer: "A", Date: "2023-01-01", Format: "vtt", VttTranscript: "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nhello there\n\n00:00:03.000 --> 00:00:04.000\ngeneral kenobi!\n\n00:00:05.000 --> 00:00:06.000\nyou are a bold one\n"},
+	}
+	for _, in := range uploads {
+		if _, err := app.insertTranscript(ctx, &in); err != nil {
+			t.Fatalf("insertTranscript failed: %v", err)
+		}
+	}
+
+	// Unchanged uploads and metadata changes don't create revisions.
+	uploads[1].StreamTitle = "Renamed"
+	if _, err := app.insertTranscript(ctx, &uploads[1]); err != nil {
+		t.Fatalf("insertTranscript failed: %v", err)
+	}
+
+	revisions, _, err := app.retrieveTranscriptRevisions(ctx, "r1")
+	if err != nil {
+		t.Fatalf("retrieveTranscriptRevisions failed: %v", err)
+	}
+	if revisions.Current != 2 || len(revisions.Revisions) != 2 {
+		t.Fatalf("Expected 2 revisions, got %+v", revisions)
+	}
+	if rev := revisions.Revisions[0]; rev.Revision != 1 || rev.Format != TranscriptFormatSRT || rev.Lines != 2 || rev.CreatedAt == "" {
+		t.Errorf("Unexpected first revision: %+v", rev)
+	}
+	if rev := revisions.Revisions[1]; rev.Revision != 2 || rev.Format != TranscriptFormatVTT || rev.Lines != 3 {
+		t.Errorf("Unexpected second revision: %+v", rev)
+	}
+
+	current, _, err := app.retrieveTranscript(ctx, "r1")
+	if err != nil || current.Revision != 2 {
+		t.Fatalf("Expected current revision 2, got %d (%v)", current.Revision, err)
+	}
+
+	// Older revisions keep their text, with the current metadata.
+	old, _, err := app.retrieveTranscriptRevision(ctx, "r1", 1)
+	if err != nil {
+		t.Fatalf("retrieveTranscriptRevision failed: %v", err)
+	}
+	if old.Revision != 1 || old.StreamTitle != "Renamed" || len(old.TranscriptLines) != 2 || old.TranscriptLines[1].Text != "general kenobi" {
+		t.Errorf("Unexpected revision 1: %+v", old)
+	}
+	if _, notFound, err := app.retrieveTranscriptRevision(ctx, "r1", 3); err == nil || !notFound {
+		t.Errorf("Expected revision 3 to be not found, got notFound=%v err=%v", notFound, err)
+	}
+
+	diff, _, err := app.diffTranscriptRevisions(ctx, "r1", 1, 0)
+	if err != nil {
+		t.Fatalf("diffTranscriptRevisions failed: %v", err)
+	}
+	wantChanges := []LineChange{
+		{Op: LineChangeRemoved, Line: TranscriptLine{ID: "1", Start: "00:00:03", StartMs: 3000, EndMs: 4000, Text: "general kenobi"}},
+		{Op: LineChangeAdded, Line: TranscriptLine{ID: "1", Start: "00:00:03", StartMs: 3000, EndMs: 4000, Text: "general kenobi!"}},
+		{Op: LineChangeAdded, Line: TranscriptLine{ID: "2", Start: "00:00:05", StartMs: 5000, EndMs: 6000, Text: "you are a bold one"}},
+	}
+	if diff.From != 1 || diff.To != 2 || diff.Added != 2 || diff.Removed != 1 || diff.Unchanged != 1 || !slices.Equal(diff.Changes, wantChanges) {
+		t.Errorf("Unexpected diff: %+v", diff)
+	}
+
+	// Deleting the transcript removes its revisions.
+	if _, err := app.deleteTranscript(ctx, "r1"); err != nil {
+		t.Fatalf("deleteTranscript failed: %v", err)
+	}
+	count := 0
+	app.db.QueryRow("SELECT COUNT(*) FROM transcript_revisions").Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected 0 revisions after delete, got %d", count)
+	}
+}
+
+func TestDatabase_LineCorrections(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	ctx := context.Background()
+
+	upload := TranscriptInput{ID: "lc", Streamer: "A", Date: "2023-01-01", SrtTranscript: "1\n00:00:05,000 --> 00:00:06,000\nplaying mine craft\n\n2\n00:00:01,000 --> 00:00:02,000\nhello chat"}
+	if _, err := app.insertTranscript(ctx, &upload); err != nil {
+		t.Fatalf("insertTranscript failed: %v", err)
+	}
+	if _, _, err := app.updateTranscriptLine(ctx, "lc", "", "1", "playing Minecraft", "duck"); err != nil {
+		t.Fatalf("updateTranscriptLine failed: %v", err)
+	}
+
+	// The correction is stored as a revision, so the current revision and diffs include it.
+	revisions, _, err := app.retrieveTranscriptRevisions(ctx, "lc")
+	if err != nil || revisions.Current != 2 || revisions.Revisions[0].Kind != RevisionKindUpload || revisions.Revisions[1].Kind != RevisionKindCorrection {
+		t.Fatalf("Expected an upload and a correction revision, got %+v, %v", revisions, err)
+	}
+	current, _, err := app.retrieveTranscriptRevision(ctx, "lc", 2)
+	if err != nil || len(current.TranscriptLines) != 2 || current.TranscriptLines[1] != (TranscriptLine{ID: "1", Start: "00:00:05", StartMs: 5000, EndMs: 6000, Text: "playing Minecraft"}) {
+		t.Errorf("Expected corrected revision 2, got %+v, %v", current.TranscriptLines, err)
+	}
+	diff, _, err := app.diffTranscriptRevisions(ctx, "lc", 1, 0)
+	if err != nil || diff.To != 2 || diff.Added != 1 || diff.Removed != 1 {
+		t.Errorf("Expected the correction in the diff, got %+v, %v", diff, err)
+	}
+
+	// A new upload keeps the IDs of lines with the same times, and the correction of a line that still has its old text.
+	upload.SrtTranscript = "1\n00:00:01,000 --> 00:00:02,000\nhello chat!\n\n2\n00:00:03,000 --> 00:00:04,000\nnew line\n\n3\n00:00:05,000 --> 00:00:06,000\nplaying mine craft"
+	if _, err := app.insertTranscript(ctx, &upload); err != nil {
+		t.Fatalf("insertTranscript failed: %v", err)
+	}
+	tr, _, err := app.retrieveTranscript(ctx, "lc")
+	if err != nil {
+		t.Fatalf("retrieveTranscript failed: %v", err)
+	}
+	want := []TranscriptLine{
+		{ID: "0", Start: "00:00:01", StartMs: 1000, EndMs: 2000, Text: "hello chat!"},
+		{ID: "2", Start: "00:00:03", StartMs: 3000, EndMs: 4000, Text: "new line"},
+		{ID: "1", Start: "00:00:05", StartMs: 5000, EndMs: 6000, Text: "playing Minecraft"},
+	}
+	if tr.Revision != 4 || !slices.Equal(tr.TranscriptLines, want) {
+		t.Errorf("Expected corrected revision 4 with kept IDs, got %d %+v", tr.Revision, tr.TranscriptLines)
+	}
+	upload3, _, err := app.retrieveTranscriptRevision(ctx, "lc", 3)
+	if err != nil || upload3.TranscriptLines[2].ID != "1" || upload3.TranscriptLines[2].Text != "playing mine craft" {
+		t.Errorf("Expected uploaded revision 3 with its own text, got %+v, %v", upload3.TranscriptLines, err)
+	}
+
+	// The same upload again is unchanged, and keeps the correction.
+	if status, err := app.insertTranscript(ctx, &upload); err != nil || status != TranscriptStatusUnchanged {
+		t.Errorf("Expected unchanged upload, got %s, %v", status, err)
+	}
+
+	// An upload that changes the corrected line replaces the correction.
+	upload.SrtTranscript = "1\n00:00:05,000 --> 00:00:06,000\nplaying Minecraft 2"
+	if _, err := app.insertTranscript(ctx, &upload); err != nil {
+		t.Fatalf("insertTranscript failed: %v", err)
+	}
+	tr, _, err = app.retrieveTranscript(ctx, "lc")
+	if err != nil || tr.Revision != 5 || len(tr.TranscriptLines) != 1 || tr.TranscriptLines[0].ID != "1" || tr.TranscriptLines[0].Text != "playing Minecraft 2" {
+		t.Errorf("Expected uploaded text at revision 5, got %d %+v, %v", tr.Revision, tr.TranscriptLines, err)
+	}
+
+	// New lines never reuse the ID of a removed line.
+	if _, _, err := app.updateTranscriptLine(ctx, "lc", "", "0", "hi", "duck"); err == nil {
+		t.Errorf("Expected removed line 0 to be not found")
+	}
+
+	edits, _, err := app.retrieveTranscriptEdits(ctx, "lc", "")
+	if err != nil || len(edits.Edits) != 1 {
+		t.Fatalf("Expected 1 edit, got %+v, %v", edits, err)
+	}
+	if edit := edits.Edits[0]; edit.LineID != "1" || edit.Revision != 1 || edit.OldText != "playing mine craft" || edit.NewText != "playing Minecraft" || edit.Editor != "duck" {
+		t.Errorf("Unexpected edit: %+v", edit)
+	}
+	if _, notFound, err := app.retrieveTranscriptEdits(ctx, "lc", "ja"); err == nil || !notFound {
+		t.Errorf("Expected missing track to be not found, got notFound=%v err=%v", notFound, err)
+	}
+}
+
+func TestDatabase_SpeakerFilter(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	ctx := context.Background()
+
+	inputs := []TranscriptInput{
+		{ID: "collab", Streamer: "A", Date: "2023-01-01", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\n[Ame]: hello chat\n\n2\n00:00:03,000 --> 00:00:04,000\n[Gura]: hello everyone\n\n3\n00:00:05,000 --> 00:00:06,000\n[Gura]: a shark fact\n\n"},
+		{ID: "solo", Streamer: "A", Date: "2023-01-02", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nhello solo\n\n"},
+	}
+	for _, in := range inputs {
+		if _, err := app.insertTranscript(ctx, &in); err != nil {
+			t.Fatalf("insertTranscript failed: %v", err)
+		}
+	}
+
+	tr, _, err := app.retrieveTranscript(ctx, "collab")
+	if err != nil {
+		t.Fatalf("retrieveTranscript failed: %v", err)
+	}
+	if tr.TranscriptLines[0].Speaker != "Ame" || tr.TranscriptLines[0].Text != "hello chat" {
+		t.Errorf("Unexpected first line: %+v", tr.TranscriptLines[0])
+	}
+
+	// Search only matches lines by the speaker, ignoring case.
+	res, err := app.queryTranscripts(ctx, QueryData{SearchText: "hello", Speakers: []string{"gura"}})
+	if err != nil {
+		t.Fatalf("queryTranscripts failed: %v", err)
+	}
+	if len(res.Result) != 1 || len(res.Result[0].Contexts) != 1 {
+		t.Fatalf("Expected 1 result with 1 context, got %+v", res.Result)
+	}
+	if ctx0 := res.Result[0].Contexts[0]; ctx0.Speaker != "Gura" || ctx0.Line != "hello everyone" {
+		t.Errorf("Unexpected context: %+v", ctx0)
+	}
+
+	// Without search text, transcripts with any line by the speaker are returned.
+	res, err = app.queryTranscripts(ctx, QueryData{Speakers: []string{"Ame", "Kronii"}})
+	if err != nil || len(res.Result) != 1 || res.Result[0].ID != "collab" {
+		t.Errorf("Expected only collab, got %+v, %v", res.Result, err)
+	}
+
+	graph, err := app.querySingleGraph(ctx, "collab", QueryData{SearchText: "hello", Speakers: []string{"Ame"}})
+	if err != nil || len(graph.Result) != 1 || graph.Result[0].X != "00:00:01" {
+		t.Errorf("Unexpected single graph: %+v, %v", graph.Result, err)
+	}
+	graph, err = app.queryAllGraphs(ctx, QueryData{SearchText: "hello", Speakers: []string{"Gura"}})
+	if err != nil || len(graph.Result) != 1 || graph.Result[0] != (GraphDataPoint{X: "2023-01-01", Y: 1}) {
+		t.Errorf("Unexpected all graph: %+v, %v", graph.Result, err)
+	}
+}
+
+func TestDatabase_OffsetFilter(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	ctx := context.Background()
+
+	inputs := []TranscriptInput{
+		{ID: "o1", Streamer: "A", Date: "2023-01-01", SrtTranscript: "1\n00:00:10,000 --> 00:00:12,000\nhello chat\n\n" +
+			"2\n00:30:00,500 --> 00:30:02,000\nhello again\n\n" +
+			"3\n01:10:00,000 --> 01:10:02,000\nhello before the end\n\n"},
+		{ID: "o2", Streamer: "A", Date: "2023-01-02", SrtTranscript: "1\n00:45:00,000 --> 00:45:02,000\nhello late\n\n"},
+	}
+	for _, in := range inputs {
+		if _, err := app.insertTranscript(ctx, &in); err != nil {
+			t.Fatalf("insertTranscript failed: %v", err)
+		}
+	}
+	ms := func(v int64) *int64 { return &v }
+
+	// The upper bound includes the lines starting during its second.
+	firstHalfHour := QueryData{SearchText: "hello", ToOffset: ms(30 * 60 * 1000)}
+	res, err := app.queryTranscripts(ctx, firstHalfHour)
+	if err != nil || len(res.Result) != 1 || res.Result[0].ID != "o1" || len(res.Result[0].Contexts) != 2 || res.TotalContexts != 2 {
+		t.Fatalf("Unexpected first half hour results: %+v, %v", res, err)
+	}
+
+	res, err = app.queryTranscripts(ctx, QueryData{SearchText: "hello", FromOffset: ms(40 * 60 * 1000), ToOffset: ms(60 * 60 * 1000)})
+	if err != nil || len(res.Result) != 1 || res.Result[0].ID != "o2" {
+		t.Errorf("Expected only o2, got %+v, %v", res.Result, err)
+	}
+
+	// Without search text, transcripts with any line in the range are returned.
+	res, err = app.queryTranscripts(ctx, QueryData{FromOffset: ms(60 * 60 * 1000)})
+	if err != nil || len(res.Result) != 1 || res.Result[0].ID != "o1" {
+		t.Errorf("Expected only o1, got %+v, %v", res.Result, err)
+	}
+
+	graph, err := app.querySingleGraph(ctx, "o1", firstHalfHour)
+	if err != nil || len(graph.Result) != 2 || graph.Result[1].X != "00:30:00" {
+		t.Errorf("Unexpected single graph: %+v, %v", graph.Result, err)
+	}
+	graph, err = app.queryAllGraphs(ctx, firstHalfHour)
+	if err != nil || len(graph.Result) != 1 || graph.Result[0] != (GraphDataPoint{X: "2023-01-01", Y: 2}) {
+		t.Errorf("Unexpected all graph: %+v, %v", graph.Result, err)
+	}
+}
+
+func TestDatabase_LanguageTracks(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	ctx := context.Background()
+
+	en := TranscriptInput{ID: "s1", Streamer: "A", Date: "2023-01-01", Lang: "en", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nhello world\n\n"}
+	ja := TranscriptInput{ID: "s1", Streamer: "A", Date: "2023-01-01", Lang: "JA", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nkonnichiwa world\n\n2\n00:00:03,000 --> 00:00:04,000\nsayonara\n\n"}
+	for _, in := range []TranscriptInput{en, ja} {
+		if status, err := app.insertTranscript(ctx, &in); err != nil || status != TranscriptStatusCreated {
+			t.Fatalf("insertTranscript: got %q, %v", status, err)
+		}
+	}
+
+	// Uploading a second language does not replace the first one, and re-uploading a track is skipped.
+	if status, err := app.insertTranscript(ctx, &en); err != nil || status != TranscriptStatusUnchanged {
+		t.Errorf("Re-upload of en track: got %q, %v", status, err)
+	}
+
+	tr, _, err := app.retrieveTranscript(ctx, "s1")
+	if err != nil {
+		t.Fatalf("retrieveTranscript failed: %v", err)
+	}
+	if tr.Lang != "en" || !slices.Equal(tr.Langs, []string{"en", "ja"}) || len(tr.TranscriptLines) != 1 || tr.Revision != 1 {
+		t.Errorf("Unexpected default track: %+v", tr)
+	}
+
+	tr, _, err = app.retrieveTranscriptTrack(ctx, "s1", "ja")
+	if err != nil {
+		t.Fatalf("retrieveTranscriptTrack failed: %v", err)
+	}
+	if tr.Lang != "ja" || len(tr.TranscriptLines) != 2 || tr.TranscriptLines[1].Text != "sayonara" || tr.Revision != 2 {
+		t.Errorf("Unexpected ja track: %+v", tr)
+	}
+
+	if _, notFound, err := app.retrieveTranscriptTrack(ctx, "s1", "fr"); err == nil || !notFound {
+		t.Errorf("Expected notFound for unknown track, got %v, %v", notFound, err)
+	}
+
+	// Search covers the default track unless filtered, like graphs.
+	res, err := app.queryTranscripts(ctx, QueryData{SearchText: "world"})
+	if err != nil || len(res.Result) != 1 || len(res.Result[0].Contexts) != 1 || res.Result[0].Contexts[0].Lang != "en" {
+		t.Fatalf("Expected only the en context, got %+v, %v", res.Result, err)
+	}
+	res, err = app.queryTranscripts(ctx, QueryData{SearchText: "world", Langs: []string{"en", "ja"}})
+	if err != nil || len(res.Result) != 1 || len(res.Result[0].Contexts) != 2 {
+		t.Fatalf("Expected 1 result with 2 contexts, got %+v, %v", res.Result, err)
+	}
+	res, err = app.queryTranscripts(ctx, QueryData{SearchText: "world", Langs: []string{"ja"}})
+	if err != nil || len(res.Result) != 1 || len(res.Result[0].Contexts) != 1 || res.Result[0].Contexts[0].Lang != "ja" {
+		t.Errorf("Expected only the ja context, got %+v, %v", res.Result, err)
+	}
+	res, err = app.queryTranscripts(ctx, QueryData{SearchText: "sayonara", Langs: []string{"en"}})
+	if err != nil || len(res.Result) != 0 {
+		t.Errorf("Expected no results in the en track, got %+v, %v", res.Result, err)
+	}
+
+	// Corrections only apply to the selected track.
+	if _, _, err := app.updateTranscriptLine(ctx, "s1", "ja", "1", "mata ne", "mod"); err != nil {
+		t.Fatalf("updateTranscriptLine failed: %v", err)
+	}
+	tr, _, _ = app.retrieveTranscript(ctx, "s1")
+	if tr.TranscriptLines[0].Text != "hello world" {
+		t.Errorf("en track changed by ja correction: %+v", tr.TranscriptLines)
+	}
+	tr, _, _ = app.retrieveTranscriptTrack(ctx, "s1", "ja")
+	if tr.TranscriptLines[1].Text != "mata ne" {
+		t.Errorf("ja correction not applied: %+v", tr.TranscriptLines)
+	}
+
+	// The default diff target is the latest revision of the same track.
+	diff, _, err := app.diffTranscriptRevisions(ctx, "s1", 1, 0)
+	if err != nil || diff.To != 1 || len(diff.Changes) != 0 {
+		t.Errorf("Unexpected diff: %+v, %v", diff, err)
+	}
+	if _, notFound, err := app.diffTranscriptRevisions(ctx, "s1", 1, 2); !errors.Is(err, errRevisionTrackMismatch) || notFound {
+		t.Errorf("Expected a track mismatch diffing en and ja revisions, got notFound=%v err=%v", notFound, err)
+	}
+
+	// Graphs count the default track unless filtered, so a line and its translation are counted once.
+	graph, err := app.querySingleGraph(ctx, "s1", QueryData{SearchText: "world"})
+	if err != nil || !slices.Equal(graph.Result, []GraphDataPoint{{X: "00:00:01", Y: 1}}) {
+		t.Errorf("Expected 1 match in the default track, got %+v, %v", graph.Result, err)
+	}
+	graph, err = app.querySingleGraph(ctx, "s1", QueryData{SearchText: "world", Langs: []string{"en", "ja"}})
+	if err != nil || !slices.Equal(graph.Result, []GraphDataPoint{{X: "00:00:01", Y: 2}}) {
+		t.Errorf("Expected 2 matches across both tracks, got %+v, %v", graph.Result, err)
+	}
+	graph, err = app.queryAllGraphs(ctx, QueryData{SearchText: "world"})
+	if err != nil || !slices.Equal(graph.Result, []GraphDataPoint{{X: "2023-01-01", Y: 1}}) {
+		t.Errorf("Expected 1 match in the default track, got %+v, %v", graph.Result, err)
+	}
+	graph, err = app.queryAllGraphs(ctx, QueryData{SearchText: "konnichiwa", Langs: []string{"ja"}})
+	if err != nil || !slices.Equal(graph.Result, []GraphDataPoint{{X: "2023-01-01", Y: 1}}) {
+		t.Errorf("Expected 1 match in the ja track, got %+v, %v", graph.Result, err)
+	}
+}
+
+func TestDatabase_UntaggedTrack(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	ctx := context.Background()
+
+	// The ja track is uploaded first, so it is the default track and the untagged track needs its own lang.
+	ja := TranscriptInput{ID: "u1", Streamer: "A", Date: "2023-01-01", Lang: "ja", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nkonnichiwa\n\n"}
+	untagged := TranscriptInput{ID: "u1", Streamer: "A", Date: "2023-01-01", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nhello\n\n"}
+	for _, in := range []TranscriptInput{ja, untagged} {
+		if status, err := app.insertTranscript(ctx, &in); err != nil || status != TranscriptStatusCreated {
+			t.Fatalf("insertTranscript: got %q, %v", status, err)
+		}
+	}
+
+	tr, _, err := app.retrieveTranscript(ctx, "u1")
+	if err != nil || tr.Lang != "ja" || !slices.Equal(tr.Langs, []string{"ja", ""}) {
+		t.Fatalf("Unexpected default track: %+v, %v", tr, err)
+	}
+	tr, _, err = app.retrieveTranscriptTrack(ctx, "u1", untaggedLang)
+	if err != nil || tr.Lang != "" || len(tr.TranscriptLines) != 1 || tr.TranscriptLines[0].Text != "hello" {
+		t.Fatalf("Unexpected untagged track: %+v, %v", tr, err)
+	}
+
+	// Search and graphs select the same track: the default one, or the untagged one with its lang.
+	for _, tt := range []struct {
+		langs []string
+		found bool
+	}{
+		{nil, false},
+		{[]string{"ja"}, false},
+		{[]string{untaggedLang}, true},
+	} {
+		queryData := QueryData{SearchText: "hello", Langs: tt.langs}
+		res, err := app.queryTranscripts(ctx, queryData)
+		if err != nil || (len(res.Result) == 1) != tt.found {
+			t.Errorf("Langs %v: expected found=%v in search, got %+v, %v", tt.langs, tt.found, res.Result, err)
+		}
+		graph, err := app.querySingleGraph(ctx, "u1", queryData)
+		if err != nil || (len(graph.Result) == 1) != tt.found {
+			t.Errorf("Langs %v: expected found=%v in the graph, got %+v, %v", tt.langs, tt.found, graph.Result, err)
+		}
+		graph, err = app.queryAllGraphs(ctx, queryData)
+		if err != nil || (len(graph.Result) == 1) != tt.found {
+			t.Errorf("Langs %v: expected found=%v in all graphs, got %+v, %v", tt.langs, tt.found, graph.Result, err)
+		}
+		matches, _, err := app.searchTranscriptLines(ctx, "u1", queryData)
+		if err != nil || (matches.Total == 1) != tt.found {
+			t.Errorf("Langs %v: expected found=%v in the transcript, got %+v, %v", tt.langs, tt.found, matches, err)
+		}
+	}
+}
+
+func TestDatabase_CJKSearch(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	ctx := context.Background()
+
+	inputs := []TranscriptInput{
+		{ID: "ja", Streamer: "A", Date: "2023-01-01", Lang: "ja", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\n昨日は猫が好きだと言った。\n\n2\n00:00:03,000 --> 00:00:04,000\n猫カフェに行きたい\n\n3\n00:00:05,000 --> 00:00:06,000\n今日はMinecraftをやります\n\n"},
+		{ID: "zh", Streamer: "A", Date: "2023-01-02", Lang: "zh", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\n我非常喜欢猫。\n\n"},
+	}
+	for _, in := range inputs {
+		if _, err := app.insertTranscript(ctx, &in); err != nil {
+			t.Fatalf("insertTranscript failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		searchText     string
+		matchWholeWord bool
+		wantIDs        []string
+		wantContexts   int
+	}{
+		{"猫", false, []string{"ja", "zh"}, 3},
+		{"好き", false, []string{"ja"}, 1},
+		{"好き", true, []string{"ja"}, 1},
+		{"喜欢", false, []string{"zh"}, 1},
+		{"か", false, nil, 0}, // Does not match the voiced が
+		{"猫カフェ", false, []string{"ja"}, 1},
+		{"minecraft", true, []string{"ja"}, 1},
+	}
+	for _, tt := range tests {
+		res, err := app.queryTranscripts(ctx, QueryData{SearchText: tt.searchText, MatchWholeWord: tt.matchWholeWord})
+		if err != nil {
+			t.Fatalf("queryTranscripts(%q) failed: %v", tt.searchText, err)
+		}
+		var ids []string
+		contexts := 0
+		for _, r := range res.Result {
+			ids = append(ids, r.ID)
+			contexts += len(r.Contexts)
+		}
+		slices.Sort(ids)
+		if !slices.Equal(ids, tt.wantIDs) || contexts != tt.wantContexts {
+			t.Errorf("queryTranscripts(%q, %t): got %v with %d contexts, want %v with %d", tt.searchText, tt.matchWholeWord, ids, contexts, tt.wantIDs, tt.wantContexts)
+		}
+	}
+
+	graph, err := app.querySingleGraph(ctx, "ja", QueryData{SearchText: "猫"})
+	if err != nil || len(graph.Result) != 2 {
+		t.Errorf("Unexpected single graph: %+v, %v", graph.Result, err)
+	}
+	graph, err = app.queryAllGraphs(ctx, QueryData{SearchText: "猫", MatchWholeWord: true})
+	if err != nil || len(graph.Result) != 2 || graph.Result[0] != (GraphDataPoint{X: "2023-01-01", Y: 2}) {
+		t.Errorf("Unexpected all graph: %+v, %v", graph.Result, err)
+	}
+
+	// Lines stored before CJK segmentation are normalized again by the migration.
+	if _, err := app.db.Exec("UPDATE transcript_lines SET clean_text = '我非常喜欢猫' WHERE transcript_id = 'zh'; PRAGMA user_version = 0"); err != nil {
+		t.Fatalf("Failed to simulate old clean text: %v", err)
+	}
+	res, _ := app.queryTranscripts(ctx, QueryData{SearchText: "喜欢"})
+	if len(res.Result) != 0 {
+		t.Fatalf("Expected old clean text not to match, got %+v", res.Result)
+	}
+	if err := migrateDB(app.db); err != nil {
+		t.Fatalf("migrateDB failed: %v", err)
+	}
+	res, _ = app.queryTranscripts(ctx, QueryData{SearchText: "喜欢"})
+	if len(res.Result) != 1 || res.Result[0].ID != "zh" {
+		t.Errorf("Expected zh after migration, got %+v", res.Result)
+	}
+
+	// The lines are only scanned once.
+	if _, err := app.db.Exec("UPDATE transcript_lines SET clean_text = '我非常喜欢猫' WHERE transcript_id = 'zh'"); err != nil {
+		t.Fatalf("Failed to simulate old clean text: %v", err)
+	}
+	if err := migrateDB(app.db); err != nil {
+		t.Fatalf("migrateDB failed: %v", err)
+	}
+	res, _ = app.queryTranscripts(ctx, QueryData{SearchText: "喜欢"})
+	if len(res.Result) != 0 {
+		t.Errorf("Expected lines not to be normalized again, got %+v", res.Result)
+	}
+}
+
+func TestDatabase_SearchOperators(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	ctx := context.Background()
+
+	in := TranscriptInput{ID: "ops", Streamer: "A", Date: "2023-01-01", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nthe cat sat on the mat\n\n" +
+		"2\n00:00:03,000 --> 00:00:04,000\nthe dog chased the cat\n\n" +
+		"3\n00:00:05,000 --> 00:00:06,000\nsubscribe to the channel\n\n" +
+		"4\n00:00:07,000 --> 00:00:08,000\na dogged effort\n\n"}
+	if _, err := app.insertTranscript(ctx, &in); err != nil {
+		t.Fatalf("insertTranscript failed: %v", err)
+	}
+
+	tests := []struct {
+		searchText     string
+		matchWholeWord bool
+		wantLines      []string
+	}{
+		{"cat AND dog", false, []string{"the dog chased the cat"}},
+		{"cat -dog", false, []string{"the cat sat on the mat"}},
+		{"mat OR channel", false, []string{"the cat sat on the mat", "subscribe to the channel"}},
+		{`"the cat" "the mat"`, false, []string{"the cat sat on the mat"}},
+		{"sub*", false, []string{"subscribe to the channel"}},
+		{"dog*", false, []string{"the dog chased the cat", "a dogged effort"}},
+		{"dog* -cat", true, []string{"a dogged effort"}},
+		{"dog OR mat", true, []string{"the cat sat on the mat", "the dog chased the cat"}},
+	}
+	for _, tt := range tests {
+		res, err := app.queryTranscripts(ctx, QueryData{SearchText: tt.searchText, MatchWholeWord: tt.matchWholeWord})
+		if err != nil {
+			t.Fatalf("queryTranscripts(%q) failed: %v", tt.searchText, err)
+		}
+		var lines []string
+		for _, r := range res.Result {
+			for _, c := range r.Contexts {
+				lines = append(lines, c.Line)
+			}
+		}
+		if !slices.Equal(lines, tt.wantLines) {
+			t.Errorf("queryTranscripts(%q, %t) = %q, want %q", tt.searchText, tt.matchWholeWord, lines, tt.wantLines)
+		}
+	}
+
+	graph, err := app.querySingleGraph(ctx, "ops", QueryData{SearchText: "cat OR dog", MatchWholeWord: true})
+	if err != nil {
+		t.Fatalf("querySingleGraph failed: %v", err)
+	}
+	want := []GraphDataPoint{{X: "00:00:01", Y: 1}, {X: "00:00:03", Y: 2}}
+	if !slices.Equal(graph.Result, want) {
+		t.Errorf("querySingleGraph = %+v, want %+v", graph.Result, want)
+	}
+
+	graph, err = app.queryAllGraphs(ctx, QueryData{SearchText: "cat -mat"})
+	if err != nil || len(graph.Result) != 1 || graph.Result[0].Y != 1 {
+		t.Errorf("Unexpected all graph: %+v, %v", graph.Result, err)
+	}
+}
+
+func TestDatabase_NearSearch(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	ctx := context.Background()
+
+	in := TranscriptInput{ID: "near", Streamer: "A", Date: "2023-01-01", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nlet's play minecraft\n\n" +
+		"2\n00:00:03,000 --> 00:00:04,000\nagain today\n\n" +
+		"3\n00:00:05,000 --> 00:00:06,000\nsomething else entirely\n\n" +
+		"4\n00:00:07,000 --> 00:00:08,000\nterraria\n\n" +
+		"5\n00:00:09,000 --> 00:00:10,000\nnothing in between\n\n" +
+		"6\n00:00:11,000 --> 00:00:12,000\nagain\n\n"}
+	other := TranscriptInput{ID: "other", Streamer: "A", Date: "2023-01-02", Lang: "ja", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nminecraft\n\n"}
+	for _, in := range []TranscriptInput{in, other} {
+		if _, err := app.insertTranscript(ctx, &in); err != nil {
+			t.Fatalf("insertTranscript failed: %v", err)
+		}
+	}
+	// Another track of the same stream is not adjacent to its lines.
+	track := TranscriptInput{ID: "other", Streamer: "A", Date: "2023-01-02", Lang: "en", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nagain\n\n"}
+	if _, err := app.insertTranscript(ctx, &track); err != nil {
+		t.Fatalf("insertTranscript failed: %v", err)
+	}
+
+	distance := func(n int) *int { return &n }
+	tests := []struct {
+		queryData QueryData
+		wantLines []string
+	}{
+		// Both lines of a match across lines are returned as contexts.
+		{QueryData{SearchText: "minecraft NEAR again"}, []string{"let's play minecraft", "again today"}},
+		{QueryData{SearchText: "minecraft NEAR/1 again"}, []string{"let's play minecraft", "again today"}},
+		{QueryData{SearchText: "minecraft NEAR/0 again"}, []string{"let's play minecraft", "again today"}},
+		{QueryData{SearchText: "minecraft NEAR today"}, []string{"let's play minecraft", "again today"}},
+		{QueryData{SearchText: "minecraft NEAR/0 today"}, nil},
+		{QueryData{SearchText: "minecraft NEAR again", NearDistance: distance(0)}, []string{"let's play minecraft", "again today"}},
+		{QueryData{SearchText: "minecraft NEAR today", NearDistance: distance(0)}, nil},
+		{QueryData{SearchText: "play NEAR today", NearDistance: distance(1)}, nil},
+		{QueryData{SearchText: "play NEAR today", NearDistance: distance(2)}, []string{"let's play minecraft", "again today"}},
+		// Lines further apart are not near, even if the words are.
+		{QueryData{SearchText: "terraria NEAR again"}, nil},
+		{QueryData{SearchText: "terraria OR minecraft NEAR again"}, []string{"let's play minecraft", "again today", "terraria"}},
+	}
+	for _, tt := range tests {
+		res, err := app.queryTranscripts(ctx, tt.queryData)
+		if err != nil {
+			t.Fatalf("queryTranscripts(%q) failed: %v", tt.queryData.SearchText, err)
+		}
+		var lines []string
+		for _, r := range res.Result {
+			for _, c := range r.Contexts {
+				lines = append(lines, c.Line)
+			}
+		}
+		if !slices.Equal(lines, tt.wantLines) {
+			near := "default"
+			if tt.queryData.NearDistance != nil {
+				near = fmt.Sprint(*tt.queryData.NearDistance)
+			}
+			t.Errorf("queryTranscripts(%q, near=%s) = %q, want %q", tt.queryData.SearchText, near, lines, tt.wantLines)
+		}
+	}
+
+	graph, err := app.querySingleGraph(ctx, "near", QueryData{SearchText: "minecraft NEAR again"})
+	want := []GraphDataPoint{{X: "00:00:01", Y: 1}, {X: "00:00:03", Y: 1}}
+	if err != nil || !slices.Equal(graph.Result, want) {
+		t.Errorf("querySingleGraph = %+v, %v, want %+v", graph.Result, err, want)
+	}
+	graph, err = app.queryAllGraphs(ctx, QueryData{SearchText: "minecraft NEAR again"})
+	if err != nil || len(graph.Result) != 1 || graph.Result[0] != (GraphDataPoint{X: "2023-01-01", Y: 2}) {
+		t.Errorf("Unexpected all graph: %+v, %v", graph.Result, err)
+	}
+}
+
+func TestDatabase_RegexSearch(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	ctx := context.Background()
+
+	in := TranscriptInput{ID: "regex", Streamer: "A", Date: "2023-01-01", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nwaaah that's scary\n\n" +
+		"2\n00:00:03,000 --> 00:00:04,000\nPOGGERS, pog pog\n\n" +
+		"3\n00:00:05,000 --> 00:00:06,000\nthe code is 1234\n\n" +
+		"4\n00:00:07,000 --> 00:00:08,000\npogchamp\n\n"}
+	if _, err := app.insertTranscript(ctx, &in); err != nil {
+		t.Fatalf("insertTranscript failed: %v", err)
+	}
+
+	search := func(pattern string) []string {
+		t.Helper()
+		res, err := app.queryTranscripts(ctx, QueryData{SearchText: pattern, SearchMode: SearchModeRegex})
+		if err != nil {
+			t.Fatalf("queryTranscripts(%q) failed: %v", pattern, err)
+		}
+		var lines []string
+		for _, r := range res.Result {
+			for _, c := range r.Contexts {
+				lines = append(lines, c.Line)
+			}
+		}
+		return lines
+	}
+
+	tests := []struct {
+		pattern   string
+		wantLines []string
+	}{
+		{`wa+h+`, []string{"waaah that's scary"}},
+		{`(?i)\bpog(gers)?\b`, []string{"POGGERS, pog pog"}},
+		{`\bpog\b`, []string{"POGGERS, pog pog"}},
+		{`pog`, []string{"POGGERS, pog pog", "pogchamp"}},
+		{`POGGERS,`, []string{"POGGERS, pog pog"}}, // Punctuation is matched in the original text
+		{`[0-9]{4}`, []string{"the code is 1234"}},
+		{`that's`, []string{"waaah that's scary"}},
+		{`nothing`, nil},
+	}
+	for _, tt := range tests {
+		if lines := search(tt.pattern); !slices.Equal(lines, tt.wantLines) {
+			t.Errorf("queryTranscripts(%q) = %q, want %q", tt.pattern, lines, tt.wantLines)
+		}
+	}
+
+	// Edited lines are found through the trigram index.
+	if _, _, err := app.updateTranscriptLine(ctx, "regex", "", "3", "pogchamp again", "mod"); err != nil {
+		t.Fatalf("updateTranscriptLine failed: %v", err)
+	}
+	if lines := search(`champ again`); !slices.Equal(lines, []string{"pogchamp again"}) {
+		t.Errorf("Expected the edited line, got %q", lines)
+	}
+
+	// The index is rebuilt by the migration if it's missing.
+	if _, err := app.db.Exec("DROP TABLE transcript_trigram"); err != nil {
+		t.Fatalf("Failed to drop trigram index: %v", err)
+	}
+	if err := migrateDB(app.db); err != nil {
+		t.Fatalf("migrateDB failed: %v", err)
+	}
+	if lines := search(`scary`); !slices.Equal(lines, []string{"waaah that's scary"}) {
+		t.Errorf("Expected a match after the rebuild, got %q", lines)
+	}
+
+	graph, err := app.querySingleGraph(ctx, "regex", QueryData{SearchText: `(?i)pog`, SearchMode: SearchModeRegex})
+	want := []GraphDataPoint{{X: "00:00:03", Y: 3}, {X: "00:00:07", Y: 1}}
+	if err != nil || !slices.Equal(graph.Result, want) {
+		t.Errorf("querySingleGraph = %+v, %v, want %+v", graph.Result, err, want)
+	}
+	graph, err = app.queryAllGraphs(ctx, QueryData{SearchText: `(?i)pog`, SearchMode: SearchModeRegex})
+	if err != nil || len(graph.Result) != 1 || graph.Result[0] != (GraphDataPoint{X: "2023-01-01", Y: 4}) {
+		t.Errorf("Unexpected all graph: %+v, %v", graph.Result, err)
+	}
+
+	// Slow searches are cut off.
+	defer func(timeout time.Duration) { regexSearchTimeout = timeout }(regexSearchTimeout)
+	regexSearchTimeout = time.Nanosecond
+	if _, err := app.queryTranscripts(ctx, QueryData{SearchText: `pog`, SearchMode: SearchModeRegex}); !errors.Is(err, errSearchTimeout) {
+		t.Errorf("Expected errSearchTimeout, got %v", err)
+	}
+	if _, err := app.queryAllGraphs(ctx, QueryData{SearchText: `pog`, SearchMode: SearchModeRegex}); !errors.Is(err, errSearchTimeout) {
+		t.Errorf("Expected errSearchTimeout for the graph, got %v", err)
+	}
+}
+
+func TestDatabase_FuzzySearch(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	ctx := context.Background()
+
+	inputs := []TranscriptInput{
+		{ID: "f1", Streamer: "A", Date: "2023-01-01", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\npekora is here\n\n" +
+			"2\n00:00:03,000 --> 00:00:04,000\nPekira said hi to pekora\n\n" +
+			"3\n00:00:05,000 --> 00:00:06,000\nlet's play minecraft\n\n"},
+		{ID: "f2", Streamer: "A", Date: "2023-01-02", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\npekoras everywhere\n\n"},
+		{ID: "m1", Streamer: "TestStreamer", Date: "2023-01-03", StreamType: "Members", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nsecret hololive plans\n\n"},
+	}
+	for _, in := range inputs {
+		if _, err := app.insertTranscript(ctx, &in); err != nil {
+			t.Fatalf("insertTranscript failed: %v", err)
+		}
+	}
+
+	res, err := app.queryTranscripts(ctx, QueryData{SearchText: "pekora", SearchMode: SearchModeFuzzy})
+	if err != nil {
+		t.Fatalf("queryTranscripts failed: %v", err)
+	}
+	if len(res.Result) != 2 || len(res.Result[0].Contexts) != 1 || len(res.Result[1].Contexts) != 2 {
+		t.Fatalf("Expected both transcripts with all 3 lines, got %+v", res.Result)
+	}
+	if len(res.Fuzzy) != 1 || res.Fuzzy[0].Term != "pekora" ||
+		!slices.Equal(res.Fuzzy[0].Variants, []string{"pekora", "pekira", "pekoras"}) ||
+		!slices.Equal(res.Fuzzy[0].Matched, []string{"pekora", "pekira", "pekoras"}) {
+		t.Errorf("Unexpected fuzzy terms: %+v", res.Fuzzy)
+	}
+
+	// Variants start with the same character as the word.
+	res, err = app.queryTranscripts(ctx, QueryData{SearchText: "bekora", SearchMode: SearchModeFuzzy})
+	if err != nil || len(res.Result) != 0 {
+		t.Errorf("Expected no variants with another first character, got %+v, %v", res.Result, err)
+	}
+
+	// Text mode only matches the word itself, and stemming.
+	res, err = app.queryTranscripts(ctx, QueryData{SearchText: "pekira"})
+	if err != nil || len(res.Result) != 1 || len(res.Result[0].Contexts) != 1 || res.Fuzzy != nil {
+		t.Errorf("Expected 1 exact match, got %+v, %v", res, err)
+	}
+
+	// Phrases are expanded word by word.
+	res, err = app.queryTranscripts(ctx, QueryData{SearchText: "said hi to pekoras", SearchMode: SearchModeFuzzy, MatchWholeWord: true})
+	if err != nil || len(res.Result) != 1 || len(res.Fuzzy) != 1 || !slices.Equal(res.Fuzzy[0].Matched, []string{"said hi to pekora"}) {
+		t.Errorf("Unexpected fuzzy phrase search: %+v, %v", res, err)
+	}
+
+	graph, err := app.querySingleGraph(ctx, "f1", QueryData{SearchText: "pekora", SearchMode: SearchModeFuzzy, MatchWholeWord: true})
+	want := []GraphDataPoint{{X: "00:00:01", Y: 1}, {X: "00:00:03", Y: 2}}
+	if err != nil || !slices.Equal(graph.Result, want) {
+		t.Errorf("querySingleGraph = %+v, %v, want %+v", graph.Result, err, want)
+	}
+
+	// Searches without results suggest corrections that have results.
+	res, err = app.queryTranscripts(ctx, QueryData{SearchText: "Minecaft"})
+	if err != nil || len(res.Result) != 0 || !slices.Equal(res.Suggestions, []string{"minecraft"}) {
+		t.Errorf("Expected a suggestion, got %+v, %v", res, err)
+	}
+	res, err = app.queryTranscripts(ctx, QueryData{SearchText: "minecaft", StreamTitles: []string{"nothing"}})
+	if err != nil || len(res.Suggestions) != 0 {
+		t.Errorf("Expected no suggestions outside the filters, got %+v, %v", res, err)
+	}
+	res, err = app.queryTranscripts(ctx, QueryData{SearchText: "hololiev"})
+	if err != nil || len(res.Suggestions) != 0 {
+		t.Errorf("Expected no suggestions from members streams, got %+v, %v", res, err)
+	}
+	res, err = app.queryTranscripts(ctx, QueryData{SearchText: "hololiev", AuthorizedChannel: "TestStreamer"})
+	if err != nil || !slices.Equal(res.Suggestions, []string{"hololive"}) {
+		t.Errorf("Expected a suggestion for an authorized member, got %+v, %v", res, err)
+	}
+}
+
+func TestDatabase_SearchPagination(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	ctx := context.Background()
+
+	var srt strings.Builder
+	for i := range 25 {
+		fmt.Fprintf(&srt, "%d\n00:00:%02d,000 --> 00:00:%02d,500\nhello number %d\n\n", i+1, i, i, i)
+	}
+	inputs := []TranscriptInput{
+		{ID: "p1", Streamer: "A", Date: "2023-01-03", SrtTranscript: srt.String()},
+		{ID: "p2", Streamer: "A", Date: "2023-01-02", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nhello there\n\n"},
+		{ID: "p3", Streamer: "A", Date: "2023-01-02", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nhello again\n\n"},
+		{ID: "p4", Streamer: "A", Date: "2023-01-01", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nhelloween\n\n"},
+	}
+	for _, in := range inputs {
+		if _, err := app.insertTranscript(ctx, &in); err != nil {
+			t.Fatalf("insertTranscript failed: %v", err)
+		}
+	}
+
+	// Pages follow each other without gaps or repeats, ties on date included.
+	var ids []string
+	queryData := QueryData{SearchText: "hello", Limit: 2}
+	for page := 0; ; page++ {
+		res, err := app.queryTranscripts(ctx, queryData)
+		if err != nil {
+			t.Fatalf("queryTranscripts failed: %v", err)
+		}
+		if res.Total != 3 || res.TotalContexts != 27 {
+			t.Errorf("Expected totals of 3 transcripts and 27 contexts, got %d and %d", res.Total, res.TotalContexts)
+		}
+		for _, r := range res.Result {
+			ids = append(ids, r.ID)
+		}
+		if res.NextCursor == "" {
+			break
+		}
+		if page > 2 {
+			t.Fatal("Too many pages")
+		}
+		queryData.Cursor = res.NextCursor
+	}
+	if want := []string{"p1", "p2", "p3"}; !slices.Equal(ids, want) {
+		t.Errorf("Paged IDs = %v, want %v", ids, want)
+	}
+
+	// Contexts are capped per transcript, and the rest can be paged through.
+	res, err := app.queryTranscripts(ctx, QueryData{SearchText: "hello", Limit: 1})
+	if err != nil || len(res.Result) != 1 || res.Result[0].TotalContexts != 25 || len(res.Result[0].Contexts) != defaultContextLimit {
+		t.Fatalf("Expected %d of 25 contexts, got %+v, %v", defaultContextLimit, res.Result, err)
+	}
+	res, err = app.queryTranscripts(ctx, QueryData{SearchText: "hello", Limit: 1, ContextLimit: 10, ContextOffset: 20})
+	if err != nil || len(res.Result) != 1 || len(res.Result[0].Contexts) != 5 || res.Result[0].Contexts[0].Line != "hello number 20" {
+		t.Errorf("Expected the last 5 contexts, got %+v, %v", res.Result, err)
+	}
+
+	// The contexts of one transcript can be paged through on their own.
+	res, err = app.queryTranscripts(ctx, QueryData{SearchText: "hello", IDs: []string{"p1"}, ContextLimit: 10, ContextOffset: 20})
+	if err != nil || res.Total != 1 || len(res.Result) != 1 || res.Result[0].ID != "p1" || len(res.Result[0].Contexts) != 5 {
+		t.Errorf("Expected the last 5 contexts of p1 alone, got %+v, %v", res.Result, err)
+	}
+	res, err = app.queryTranscripts(ctx, QueryData{SearchText: "hello", IDs: []string{"p2", "p3", "p4"}})
+	if err != nil || res.Total != 2 || len(res.Result) != 2 {
+		t.Errorf("Expected p2 and p3, got %+v, %v", res.Result, err)
+	}
+
+	// Whole word matching is applied before paging and counting.
+	res, err = app.queryTranscripts(ctx, QueryData{SearchText: "hello", MatchWholeWord: true, Limit: 3})
+	if err != nil || res.Total != 3 || len(res.Result) != 3 || res.NextCursor != "" {
+		t.Errorf("Expected 3 whole word results on one page, got %+v, %v", res, err)
+	}
+
+	// Without search text, every transcript is counted.
+	res, err = app.queryTranscripts(ctx, QueryData{Limit: 1})
+	if err != nil || res.Total != 4 || res.TotalContexts != 0 || len(res.Result) != 1 || res.NextCursor == "" {
+		t.Errorf("Unexpected unfiltered page: %+v, %v", res, err)
+	}
+
+	if _, err := app.queryTranscripts(ctx, QueryData{SearchText: "hello", Cursor: "not a cursor"}); !errors.Is(err, errInvalidCursor) {
+		t.Errorf("Expected errInvalidCursor, got %v", err)
+	}
+}
+
+func TestDatabase_SearchSort(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	ctx := context.Background()
+
+	lines := func(texts ...string) string {
+		var srt strings.Builder
+		for i, text := range texts {
+			fmt.Fprintf(&srt, "%d\n00:00:%02d,000 --> 00:00:%02d,500\n%s\n\n", i+1, i, i, text)
+		}
+		return srt.String()
+	}
+	inputs := []TranscriptInput{
+		{ID: "s1", Streamer: "A", Date: "2023-01-04", StreamTitle: "delta", SrtTranscript: lines("pekora once", "nothing else", "still nothing", "and more nothing")},
+		{ID: "s2", Streamer: "A", Date: "2023-01-01", StreamTitle: "Charlie", SrtTranscript: lines("pekora", "pekora pekora", "pekora again")},
+		{ID: "s3", Streamer: "A", Date: "2023-01-02", StreamTitle: "bravo", SrtTranscript: lines("pekora", "pekora")},
+		{ID: "s4", Streamer: "A", Date: "2023-01-03", StreamTitle: "alpha", SrtTranscript: lines("nothing")},
+	}
+	for _, in := range inputs {
+		if _, err := app.insertTranscript(ctx, &in); err != nil {
+			t.Fatalf("insertTranscript failed: %v", err)
+		}
+	}
+
+	ids := func(queryData QueryData) []string {
+		t.Helper()
+		var ids []string
+		for page := 0; ; page++ {
+			res, err := app.queryTranscripts(ctx, queryData)
+			if err != nil {
+				t.Fatalf("queryTranscripts(%+v) failed: %v", queryData, err)
+			}
+			for _, r := range res.Result {
+				ids = append(ids, r.ID)
+			}
+			if res.NextCursor == "" || page > 5 {
+				return ids
+			}
+			queryData.Cursor = res.NextCursor
+		}
+	}
+
+	tests := []struct {
+		queryData QueryData
+		want      []string
+	}{
+		{QueryData{SearchText: "pekora"}, []string{"s1", "s3", "s2"}},
+		{QueryData{SearchText: "pekora", Sort: SearchSortOldest}, []string{"s2", "s3", "s1"}},
+		{QueryData{SearchText: "pekora", Sort: SearchSortRelevance}, []string{"s2", "s3", "s1"}},
+		{QueryData{SearchText: "pekora", Sort: SearchSortMatches}, []string{"s2", "s3", "s1"}},
+		{QueryData{SearchText: "pekora", Sort: SearchSortTitle}, []string{"s3", "s2", "s1"}},
+		{QueryData{Sort: SearchSortTitle}, []string{"s4", "s3", "s2", "s1"}},
+		{QueryData{Sort: SearchSortRelevance}, []string{"s1", "s4", "s3", "s2"}}, // Newest without search text
+		{QueryData{SearchText: "pek.ra", SearchMode: SearchModeRegex, Sort: SearchSortRelevance}, []string{"s2", "s3", "s1"}},
+	}
+	for _, tt := range tests {
+		for _, limit := range []int{1, 2, 10} {
+			tt.queryData.Limit = limit
+			if got := ids(tt.queryData); !slices.Equal(got, tt.want) {
+				t.Errorf("queryTranscripts(%+v) = %v, want %v", tt.queryData, got, tt.want)
+			}
+		}
+	}
+
+	res, err := app.queryTranscripts(ctx, QueryData{SearchText: "pekora", Sort: SearchSortRelevance})
+	if err != nil {
+		t.Fatalf("queryTranscripts failed: %v", err)
+	}
+	for _, r := range res.Result {
+		if r.Score <= 0 {
+			t.Errorf("Expected a positive score for %s, got %f", r.ID, r.Score)
+		}
+	}
+	if !(res.Result[0].Score > res.Result[1].Score && res.Result[1].Score > res.Result[2].Score) {
+		t.Errorf("Expected decreasing scores, got %+v", res.Result)
+	}
+
+	// Cursors only continue the order they came from.
+	res, err = app.queryTranscripts(ctx, QueryData{SearchText: "pekora", Sort: SearchSortRelevance, Limit: 1})
+	if err != nil || res.NextCursor == "" {
+		t.Fatalf("Expected a next page, got %+v, %v", res, err)
+	}
+	if _, err := app.queryTranscripts(ctx, QueryData{SearchText: "pekora", Cursor: res.NextCursor}); !errors.Is(err, errInvalidCursor) {
+		t.Errorf("Expected errInvalidCursor, got %v", err)
+	}
+}
+
+func TestDatabase_SearchHighlights(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	ctx := context.Background()
+
+	long := "so today we are going to be playing some more of the game and then later on we will do the Minecraft! collab with everyone else from the company if there is time left"
+	in := TranscriptInput{ID: "h1", Streamer: "A", Date: "2023-01-01", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nMinecraft? MINECRAFT, minecraft.\n\n" +
+		"2\n00:00:03,000 --> 00:00:04,000\n" + long + "\n\n"}
+	if _, err := app.insertTranscript(ctx, &in); err != nil {
+		t.Fatalf("insertTranscript failed: %v", err)
+	}
+
+	res, err := app.queryTranscripts(ctx, QueryData{SearchText: "minecraft"})
+	if err != nil || len(res.Result) != 1 || len(res.Result[0].Contexts) != 2 {
+		t.Fatalf("Expected 2 contexts, got %+v, %v", res.Result, err)
+	}
+	contexts := res.Result[0].Contexts
+	if want := []HighlightRange{{0, 9}, {11, 20}, {22, 31}}; !slices.Equal(contexts[0].Highlights, want) {
+		t.Errorf("Highlights = %v, want %v", contexts[0].Highlights, want)
+	}
+	if contexts[1].Line != long || !slices.Equal(contexts[1].Highlights, []HighlightRange{{91, 100}}) {
+		t.Errorf("Unexpected long context: %+v", contexts[1])
+	}
+
+	res, err = app.queryTranscripts(ctx, QueryData{SearchText: "minecraft", Snippet: true})
+	if err != nil || len(res.Result) != 1 || len(res.Result[0].Contexts) != 2 {
+		t.Fatalf("Expected 2 contexts, got %+v, %v", res.Result, err)
+	}
+	snippet := res.Result[0].Contexts[1]
+	want := "___ the game and then later on we will do the Minecraft! collab with everyone else from the company if there is ___"
+	if snippet.Line != want || !slices.Equal(snippet.Highlights, []HighlightRange{{46, 55}}) {
+		t.Errorf("Unexpected snippet: %+v", snippet)
+	}
+
+	// Regex searches highlight the original text.
+	res, err = app.queryTranscripts(ctx, QueryData{SearchText: `MINECRAFT,`, SearchMode: SearchModeRegex})
+	if err != nil || len(res.Result) != 1 || !slices.Equal(res.Result[0].Contexts[0].Highlights, []HighlightRange{{11, 21}}) {
+		t.Errorf("Unexpected regex highlights: %+v, %v", res.Result, err)
+	}
+}
+
+func TestDatabase_SearchNeighbourLines(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	ctx := context.Background()
+
+	inputs := []TranscriptInput{
+		{ID: "n1", Streamer: "A", Date: "2023-01-01", Lang: "en", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nfirst\n\n" +
+			"2\n00:00:03,000 --> 00:00:04,000\npog\n\n" +
+			"3\n00:00:05,000 --> 00:00:06,000\nthird\n\n" +
+			"4\n00:00:07,000 --> 00:00:08,000\nfourth\n\n" +
+			"5\n00:00:09,000 --> 00:00:10,000\npog again\n\n"},
+		{ID: "n1", Streamer: "A", Date: "2023-01-01", Lang: "ja", SrtTranscript: "1\n00:00:02,000 --> 00:00:03,000\nほかのトラック\n\n"},
+	}
+	for _, in := range inputs {
+		if _, err := app.insertTranscript(ctx, &in); err != nil {
+			t.Fatalf("insertTranscript failed: %v", err)
+		}
+	}
+
+	res, err := app.queryTranscripts(ctx, QueryData{SearchText: "pog", Before: 1, After: 2})
+	if err != nil || len(res.Result) != 1 || len(res.Result[0].Contexts) != 2 {
+		t.Fatalf("Expected 2 contexts, got %+v, %v", res.Result, err)
+	}
+	first, second := res.Result[0].Contexts[0], res.Result[0].Contexts[1]
+	if first.Line != "pog" || first.Lang != "en" || len(first.Highlights) != 1 {
+		t.Errorf("Unexpected context: %+v", first)
+	}
+	if want := []ContextLine{{StartTime: "00:00:01", Line: "first"}}; !slices.Equal(first.Before, want) {
+		t.Errorf("Before = %+v, want %+v", first.Before, want)
+	}
+	if want := []ContextLine{{StartTime: "00:00:05", Line: "third"}, {StartTime: "00:00:07", Line: "fourth"}}; !slices.Equal(first.After, want) {
+		t.Errorf("After = %+v, want %+v", first.After, want)
+	}
+	if want := []ContextLine{{StartTime: "00:00:07", Line: "fourth"}}; second.Line != "pog again" || !slices.Equal(second.Before, want) || second.After != nil {
+		t.Errorf("Unexpected last context: %+v", second)
+	}
+
+	// Neighbours are returned for the page of contexts only.
+	res, err = app.queryTranscripts(ctx, QueryData{SearchText: "pog", After: 1, ContextLimit: 1, ContextOffset: 1})
+	if err != nil || len(res.Result) != 1 || len(res.Result[0].Contexts) != 1 || res.Result[0].Contexts[0].Line != "pog again" || res.Result[0].Contexts[0].After != nil {
+		t.Errorf("Unexpected context page: %+v, %v", res.Result, err)
+	}
+}
+
+func TestDatabase_SearchTranscriptLines(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	ctx := context.Background()
+
+	inputs := []TranscriptInput{
+		{ID: "l1", Streamer: "A", Date: "2023-01-01", Lang: "en", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nPog!\n\n" +
+			"2\n00:00:03,000 --> 00:00:04,000\nnothing here\n\n" +
+			"3\n00:00:05,000 --> 00:00:06,000\npoggers pog\n\n" +
+			"4\n00:01:00,000 --> 00:01:01,000\nsomething else\n\n"},
+		{ID: "l1", Streamer: "A", Date: "2023-01-01", Lang: "ja", SrtTranscript: "1\n00:00:02,000 --> 00:00:03,000\npog ですね\n\n"},
+		{ID: "m1", Streamer: "A", Date: "2023-01-02", StreamType: "Members", SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\npog\n\n"},
+	}
+	for _, in := range inputs {
+		if _, err := app.insertTranscript(ctx, &in); err != nil {
+			t.Fatalf("insertTranscript failed: %v", err)
+		}
+	}
+
+	res, notFound, err := app.searchTranscriptLines(ctx, "l1", QueryData{SearchText: "pog*"})
+	if err != nil || notFound {
+		t.Fatalf("searchTranscriptLines failed: %v", err)
+	}
+	want := []LineMatch{
+		{ID: "0", Start: "00:00:01", StartMs: 1000, Highlights: []HighlightRange{{0, 3}}},
+		{ID: "2", Start: "00:00:05", StartMs: 5000, Highlights: []HighlightRange{{0, 3}, {8, 11}}},
+	}
+	if res.Lang != "en" || res.Total != 2 || len(res.Matches) != 2 {
+		t.Fatalf("Unexpected matches: %+v", res)
+	}
+	for i := range want {
+		got := res.Matches[i]
+		if got.ID != want[i].ID || got.Start != want[i].Start || got.StartMs != want[i].StartMs || !slices.Equal(got.Highlights, want[i].Highlights) {
+			t.Errorf("Match %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+
+	// Whole word matching skips "poggers", and its highlights.
+	res, _, err = app.searchTranscriptLines(ctx, "l1", QueryData{SearchText: "pog", MatchWholeWord: true})
+	if err != nil || res.Total != 2 || !slices.Equal(res.Matches[1].Highlights, []HighlightRange{{8, 11}}) {
+		t.Errorf("Unexpected whole word matches: %+v, %v", res, err)
+	}
+	res, _, err = app.searchTranscriptLines(ctx, "l1", QueryData{SearchText: "nothing", MatchWholeWord: true})
+	if err != nil || res.Total != 1 || res.Matches[0].ID != "1" {
+		t.Errorf("Unexpected matches: %+v, %v", res, err)
+	}
+
+	// Other tracks are searched with lang.
+	res, _, err = app.searchTranscriptLines(ctx, "l1", QueryData{SearchText: "pog", Langs: []string{"ja"}})
+	if err != nil || res.Lang != "ja" || res.Total != 1 || res.Matches[0].Start != "00:00:02" {
+		t.Errorf("Unexpected ja matches: %+v, %v", res, err)
+	}
+	if _, notFound, err := app.searchTranscriptLines(ctx, "l1", QueryData{SearchText: "pog", Langs: []string{"fr"}}); err == nil || !notFound {
+		t.Errorf("Expected a missing track to be not found, got %v", err)
+	}
+
+	// Members transcripts are hidden without a key for the channel.
+	if _, notFound, err := app.searchTranscriptLines(ctx, "m1", QueryData{SearchText: "pog"}); err == nil || !notFound {
+		t.Errorf("Expected members transcript to be not found, got %v", err)
+	}
+	memberCtx := context.WithValue(ctx, AuthorizedChannelKey, "A")
+	if res, _, err := app.searchTranscriptLines(memberCtx, "m1", QueryData{SearchText: "pog"}); err != nil || res.Total != 1 {
+		t.Errorf("Expected members transcript to be searched, got %+v, %v", res, err)
+	}
+	if _, notFound, err := app.searchTranscriptLines(ctx, "missing", QueryData{SearchText: "pog"}); err == nil || !notFound {
+		t.Errorf("Expected missing transcript to be not found, got %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package internal
+
+// Maximum size of the table used to find the longest common subsequence in diffLines.
+// Past this, the differing middle of the two transcripts is reported as removed and re-added as a whole.
+const maxDiffCells = 4_000_000
+
+// Returns the lines removed from `from` and added in `to`, comparing line text only.
+// Changes are in transcript order, with removed lines listed before the lines that replace them.
+func diffLines(from, to []TranscriptLine) (changes []LineChange, unchanged int) {
+	// 1. Lines shared at the start and end are unchanged and don't need the table.
+	prefix := 0
+	for prefix < len(from) && prefix < len(to) && from[prefix].Text == to[prefix].Text {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(from)-prefix && suffix < len(to)-prefix && from[len(from)-1-suffix].Text == to[len(to)-1-suffix].Text {
+		suffix++
+	}
+	a := from[prefix : len(from)-suffix]
+	b := to[prefix : len(to)-suffix]
+	unchanged = prefix + suffix
+
+	if len(a)*len(b) > maxDiffCells {
+		for _, line := range a {
+			changes = append(changes, LineChange{Op: LineChangeRemoved, Line: line})
+		}
+		for _, line := range b {
+			changes = append(changes, LineChange{Op: LineChangeAdded, Line: line})
+		}
+		return changes, unchanged
+	}
+
+	// 2. lcs[i*width+j] is the length of the longest common subsequence of a[i:] and b[j:].
+	width := len(b) + 1
+	lcs := make([]int32, (len(a)+1)*width)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i].Text == b[j].Text {
+				lcs[i*width+j] = lcs[(i+1)*width+j+1] + 1
+			} else {
+				lcs[i*width+j] = max(lcs[(i+1)*width+j], lcs[i*width+j+1])
+			}
+		}
+	}
+
+	// 3. Walk the table, keeping the common lines.
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i].Text == b[j].Text:
+			unchanged++
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[(i+1)*width+j] >= lcs[i*width+j+1]):
+			changes = append(changes, LineChange{Op: LineChangeRemoved, Line: a[i]})
+			i++
+		default:
+			changes = append(changes, LineChange{Op: LineChangeAdded, Line: b[j]})
+			j++
+		}
+	}
+
+	return changes, unchanged
+}
@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	lines := func(texts ...string) []TranscriptLine {
+		var out []TranscriptLine
+		for _, text := range texts {
+			out = append(out, TranscriptLine{Text: text})
+		}
+		return out
+	}
+	removed := func(text string) LineChange {
+		return LineChange{Op: LineChangeRemoved, Line: TranscriptLine{Text: text}}
+	}
+	added := func(text string) LineChange { return LineChange{Op: LineChangeAdded, Line: TranscriptLine{Text: text}} }
+
+	tests := []struct {
+		name          string
+		from, to      []TranscriptLine
+		wantChanges   []LineChange
+		wantUnchanged int
+	}{
+		{"Identical", lines("a", "b"), lines("a", "b"), nil, 2},
+		{"Both empty", nil, nil, nil, 0},
+		{"All added", nil, lines("a", "b"), []LineChange{added("a"), added("b")}, 0},
+		{"All removed", lines("a", "b"), nil, []LineChange{removed("a"), removed("b")}, 0},
+		{"Changed middle line", lines("a", "b", "c"), lines("a", "x", "c"), []LineChange{removed("b"), added("x")}, 2},
+		{"Inserted and removed", lines("a", "b", "c", "d"), lines("b", "c", "x", "d"), []LineChange{removed("a"), added("x")}, 3},
+		{"Repeated lines", lines("a", "a", "b"), lines("a", "b", "b"), []LineChange{removed("a"), added("b")}, 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changes, unchanged := diffLines(test.from, test.to)
+			if !slices.Equal(changes, test.wantChanges) || unchanged != test.wantUnchanged {
+				t.Errorf("got %v (%d unchanged), want %v (%d unchanged)", changes, unchanged, test.wantChanges, test.wantUnchanged)
+			}
+		})
+	}
+}
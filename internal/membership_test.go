@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"testing"
 	"time"
 
@@ -57,7 +58,7 @@ func seedTestData(t *testing.T, app *App) {
 	}
 
 	for _, tr := range transcripts {
-		if err := app.insertTranscript(ctx, &tr); err != nil {
+		if _, err := app.insertTranscript(ctx, &tr); err != nil {
 			t.Fatalf("Failed to insert transcript %s: %v", tr.ID, err)
 		}
 	}
@@ -134,6 +135,27 @@ func TestMembershipVerification(t *testing.T) {
 		}
 	}
 
+	checkFuzzyVariants := func(url, key string, expectedVariants []string) {
+		req, _ := http.NewRequest("GET", url, nil)
+		if key != "" {
+			req.Header.Set("X-Membership-Key", key)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var output TranscriptSearchOutput
+		if err := json.NewDecoder(resp.Body).Decode(&output); err != nil {
+			t.Fatalf("Failed to decode query response: %v", err)
+		}
+
+		if len(output.Fuzzy) != 1 || !slices.Equal(output.Fuzzy[0].Variants, expectedVariants) {
+			t.Errorf("GET %s (key=%s): expected variants %v, got %+v", url, key, expectedVariants, output.Fuzzy)
+		}
+	}
+
 	// --- 1. Access (No Key) ---
 	t.Log("[1] Testing Access (No Key)")
 
@@ -149,6 +171,14 @@ func TestMembershipVerification(t *testing.T) {
 	checkStatus(host+"/transcript/eI8e0eDfmQs", "", http.StatusNotFound) // OtherStreamer Members
 	checkStatus(host+"/transcript/IVcjM0mQD64", "", http.StatusOK)       // OtherStreamer Public
 
+	// Transcript Revisions
+	checkStatus(host+"/transcript/eeb65mIOpfs/revisions", "", http.StatusNotFound)   // TestStreamer Members
+	checkStatus(host+"/transcript/5jvNKUzrI4Q/revisions", "", http.StatusOK)         // TestStreamer Public
+	checkStatus(host+"/transcript/eeb65mIOpfs?rev=1", "", http.StatusNotFound)       // TestStreamer Members
+	checkStatus(host+"/transcript/5jvNKUzrI4Q?rev=1", "", http.StatusOK)             // TestStreamer Public
+	checkStatus(host+"/transcript/eeb65mIOpfs/diff?from=1", "", http.StatusNotFound) // TestStreamer Members
+	checkStatus(host+"/transcript/5jvNKUzrI4Q/diff?from=1", "", http.StatusOK)       // TestStreamer Public
+
 	// Graph
 	checkGraphData(host+"/graph/eeb65mIOpfs?searchText=the", "", 0) // TestStreamer Members
 	checkGraphData(host+"/graph/5jvNKUzrI4Q?searchText=the", "", 1) // TestStreamer Public
@@ -177,6 +207,9 @@ func TestMembershipVerification(t *testing.T) {
 	checkQueryData(host+"/transcripts?searchText=the&streamer=TestStreamer", "", 1)  // 1 because there is only 1 public transcript for TestStreamer
 	checkQueryData(host+"/transcripts?searchText=the&streamer=OtherStreamer", "", 1) // 1 because there is only 1 public transcript for OtherStreamer
 
+	// Fuzzy variants only list words of transcripts that can be accessed.
+	checkFuzzyVariants(host+"/transcripts?searchText=secrat&searchMode=fuzzy", "", []string{"secrat"})
+
 	// --- 2. Create Key for TestStreamer ---
 	t.Log("[2] Creating Key for TestStreamer...")
 	createReq, _ := http.NewRequest("POST", host+"/membership/TestStreamer", nil)
@@ -230,6 +263,12 @@ func TestMembershipVerification(t *testing.T) {
 	checkStatus(host+"/transcript/eI8e0eDfmQs", testKey, http.StatusNotFound) // OtherStreamer Members (Denied) - Cross Channel
 	checkStatus(host+"/transcript/IVcjM0mQD64", testKey, http.StatusOK)       // OtherStreamer Public (Allowed)
 
+	// Transcript Revisions
+	checkStatus(host+"/transcript/eeb65mIOpfs/revisions", testKey, http.StatusOK)       // TestStreamer Members (Allowed)
+	checkStatus(host+"/transcript/eI8e0eDfmQs/revisions", testKey, http.StatusNotFound) // OtherStreamer Members (Denied) - Cross Channel
+	checkStatus(host+"/transcript/eeb65mIOpfs?rev=1", testKey, http.StatusOK)           // TestStreamer Members (Allowed)
+	checkStatus(host+"/transcript/eI8e0eDfmQs?rev=1", testKey, http.StatusNotFound)     // OtherStreamer Members (Denied) - Cross Channel
+
 	// Graph
 	checkGraphData(host+"/graph/eeb65mIOpfs?searchText=the", testKey, 1) // TestStreamer Members
 	checkGraphData(host+"/graph/5jvNKUzrI4Q?searchText=the", testKey, 1) // TestStreamer Public
@@ -258,6 +297,10 @@ func TestMembershipVerification(t *testing.T) {
 	checkQueryData(host+"/transcripts?searchText=the&streamer=TestStreamer", testKey, 2)
 	checkQueryData(host+"/transcripts?searchText=the&streamer=OtherStreamer", testKey, 1)
 
+	// Fuzzy Variants
+	checkFuzzyVariants(host+"/transcripts?searchText=secrat&searchMode=fuzzy", testKey, []string{"secrat", "secret"})
+	checkFuzzyVariants(host+"/transcripts?searchText=secrat&searchMode=fuzzy&streamer=OtherStreamer", testKey, []string{"secrat"})
+
 	// --- 5. List Keys ---
 	t.Log("[5] Listing keys...")
 	listReq, _ := http.NewRequest("GET", host+"/membership", nil)
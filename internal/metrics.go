@@ -45,6 +45,80 @@ var (
 		},
 	})
 
+	PostBulkTranscriptsRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "at_post_bulk_transcripts_requests",
+		Help: "The number of POST /transcripts/bulk requests.",
+	})
+	PostBulkTranscriptsItems = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "at_post_bulk_transcripts_items",
+		Help: "The number of transcripts received through POST /transcripts/bulk.",
+	})
+	PostBulkTranscriptsProcessingDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "at_post_bulk_transcripts_processing_duration_seconds",
+		Help: "The duration of POST /transcripts/bulk requests in seconds.",
+		Buckets: []float64{
+			0.1, 0.5, // ms
+			1, 5, 10, 30, // seconds
+			60, 300, 900, 1800, // minutes
+		},
+	})
+
+	ValidateTranscriptRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "at_validate_transcript_requests",
+		Help: "The number of POST /transcript/validate requests.",
+	})
+	ValidateTranscriptProcessingDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "at_validate_transcript_processing_duration_seconds",
+		Help: "The duration of POST /transcript/validate requests in seconds.",
+		Buckets: []float64{
+			0.001, 0.005, 0.01, 0.05, 0.1, 0.5, // ms
+			1, 2, 3, 4, 5, // seconds
+			10, 15, 20, // seconds
+		},
+	})
+
+	DeleteTranscriptRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "at_delete_transcript_requests",
+		Help: "The number of DELETE /transcript/:id requests.",
+	})
+	DeleteTranscriptProcessingDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "at_delete_transcript_processing_duration_seconds",
+		Help: "The duration of DELETE /transcript/:id requests in seconds.",
+		Buckets: []float64{
+			0.001, 0.005, 0.01, 0.05, 0.1, 0.5, // ms
+			1, 2, 3, 4, 5, // seconds
+			10, 15, 20, // seconds
+		},
+	})
+
+	PatchTranscriptRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "at_patch_transcript_requests",
+		Help: "The number of PATCH /transcript/:id requests.",
+	})
+	PatchTranscriptProcessingDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "at_patch_transcript_processing_duration_seconds",
+		Help: "The duration of PATCH /transcript/:id requests in seconds.",
+		Buckets: []float64{
+			0.001, 0.005, 0.01, 0.05, 0.1, 0.5, // ms
+			1, 2, 3, 4, 5, // seconds
+			10, 15, 20, // seconds
+		},
+	})
+
+	PatchTranscriptLineRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "at_patch_transcript_line_requests",
+		Help: "The number of PATCH /transcript/:id/lines/:lineId requests.",
+	})
+	PatchTranscriptLineProcessingDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "at_patch_transcript_line_processing_duration_seconds",
+		Help: "The duration of PATCH /transcript/:id/lines/:lineId requests in seconds.",
+		Buckets: []float64{
+			0.001, 0.005, 0.01, 0.05, 0.1, 0.5, // ms
+			1, 2, 3, 4, 5, // seconds
+			10, 15, 20, // seconds
+		},
+	})
+
 	GetTranscriptRequests = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "at_get_transcript_requests",
 		Help: "The number of GET /transcript/:id requests.",
@@ -59,6 +133,48 @@ var (
 		},
 	})
 
+	GetTranscriptRevisionsRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "at_get_transcript_revisions_requests",
+		Help: "The number of GET /transcript/:id/revisions requests.",
+	})
+	GetTranscriptRevisionsProcessingDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "at_get_transcript_revisions_processing_duration_seconds",
+		Help: "The duration of GET /transcript/:id/revisions requests in seconds.",
+		Buckets: []float64{
+			0.001, 0.005, 0.01, 0.05, 0.1, 0.5, // ms
+			1, 2, 3, 4, 5, // seconds
+			10, 15, 20, // seconds
+		},
+	})
+
+	GetTranscriptEditsRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "at_get_transcript_edits_requests",
+		Help: "The number of GET /transcript/:id/edits requests.",
+	})
+	GetTranscriptEditsProcessingDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "at_get_transcript_edits_processing_duration_seconds",
+		Help: "The duration of GET /transcript/:id/edits requests in seconds.",
+		Buckets: []float64{
+			0.001, 0.005, 0.01, 0.05, 0.1, 0.5, // ms
+			1, 2, 3, 4, 5, // seconds
+			10, 15, 20, // seconds
+		},
+	})
+
+	GetTranscriptDiffRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "at_get_transcript_diff_requests",
+		Help: "The number of GET /transcript/:id/diff requests.",
+	})
+	GetTranscriptDiffProcessingDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "at_get_transcript_diff_processing_duration_seconds",
+		Help: "The duration of GET /transcript/:id/diff requests in seconds.",
+		Buckets: []float64{
+			0.001, 0.005, 0.01, 0.05, 0.1, 0.5, // ms
+			1, 2, 3, 4, 5, // seconds
+			10, 15, 20, // seconds
+		},
+	})
+
 	SearchTranscriptsRequests = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "at_search_transcripts_requests",
 		Help: "The number of GET /transcripts requests.",
@@ -73,6 +189,20 @@ var (
 		},
 	})
 
+	SearchTranscriptRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "at_search_transcript_requests",
+		Help: "The number of GET /transcript/:id/search requests.",
+	})
+	SearchTranscriptProcessingDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "at_search_transcript_processing_duration_seconds",
+		Help: "The duration of GET /transcript/:id/search requests in seconds.",
+		Buckets: []float64{
+			0.001, 0.005, 0.01, 0.05, 0.1, 0.5, // ms
+			1, 2, 3, 4, 5, // seconds
+			10, 15, 20, // seconds
+		},
+	})
+
 	GetGraphRequests = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "at_get_graph_requests",
 		Help: "The number of GET /graph/:id requests.",
@@ -1,15 +1,31 @@
 package internal
 
 import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"html"
+	"io"
 	"net/http"
+	"net/url"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
-// Parses raw SRT content into a slice of TranscriptLine.
+// Parses raw SRT content into a slice of TranscriptLine. Invalid blocks are skipped.
 func parseSRTForLines(srtContent string) []TranscriptLine {
+	lines, _ := parseSRT(srtContent, false)
+	return lines
+}
+
+// Parses raw SRT content into a slice of TranscriptLine, and reports every block that was skipped.
+// In strict mode, blocks with a non-numeric index or an end time before the start are also rejected.
+func parseSRT(srtContent string, strict bool) ([]TranscriptLine, []RejectedBlock) {
 	// Normalize line endings and trim whitespace
 	srtContent = strings.ReplaceAll(srtContent, "\r\n", "\n")
 	srtContent = strings.TrimSpace(srtContent)
@@ -17,10 +33,19 @@ func parseSRTForLines(srtContent string) []TranscriptLine {
 	blocks := strings.Split(srtContent, "\n\n")
 	// Pre-allocate with a reasonable capacity
 	lines := make([]TranscriptLine, 0, len(blocks))
+	rejected := make([]RejectedBlock, 0)
 
+	index := 0 // 1-based position of the block, not counting extra blank lines
 	for _, block := range blocks {
+		block = strings.Trim(block, "\n")
+		if block == "" {
+			continue // Extra blank lines between blocks
+		}
+		index++
+
 		parts := strings.SplitN(block, "\n", 3)
 		if len(parts) < 3 {
+			rejected = append(rejected, RejectedBlock{Index: index, Reason: "expected an index, a timestamp line, and text", Raw: block})
 			continue // Invalid block
 		}
 
@@ -28,26 +53,344 @@ func parseSRTForLines(srtContent string) []TranscriptLine {
 		// parts[1] is the timestamp (e.g., "00:00:01,000 --> 00:00:04,000")
 		// parts[2] is the text content
 
-		// Get hh:mm:ss from "hh:mm:ss,ms --> ..."
-		if len(parts[1]) < 8 || !strings.Contains(parts[1], "-->") || !unicode.IsDigit(rune(parts[1][0])) {
+		if strict {
+			if _, err := strconv.Atoi(strings.TrimSpace(parts[0])); err != nil {
+				rejected = append(rejected, RejectedBlock{Index: index, Reason: "invalid index", Raw: block})
+				continue
+			}
+		}
+
+		startMs, endMs, ok := parseTimingLine(parts[1], strict)
+		if !ok {
+			rejected = append(rejected, RejectedBlock{Index: index, Reason: "invalid timestamp line", Raw: block})
 			continue // Invalid timestamp line
 		}
-		startTime := parts[1][0:8]
 
 		// Clean up text: remove newlines within a single block
 		text := strings.ReplaceAll(parts[2], "\n", " ")
 		text = strings.TrimSpace(text)
+		speaker, text := splitSpeaker(text)
 
-		if text != "" {
-			lines = append(lines, TranscriptLine{
-				Start: startTime,
-				Text:  text,
-			})
+		if text == "" {
+			rejected = append(rejected, RejectedBlock{Index: index, Reason: "empty text", Raw: block})
+			continue
 		}
+		lines = append(lines, TranscriptLine{
+			Start:   formatTimestamp(startMs),
+			StartMs: startMs,
+			EndMs:   endMs,
+			Speaker: speaker,
+			Text:    text,
+		})
+	}
+	return lines, rejected
+}
+
+// Matches an SRT or WebVTT timestamp: optional hours, then mm:ss followed by a ',' or '.' and a fraction of a second.
+// The fraction is three digits of milliseconds in strict mode, and is allowed one to three digits otherwise.
+var timestampRegex = regexp.MustCompile(`^(?:(\d+):)?([0-5]\d):([0-5]\d)[,.](\d{1,3})$`)
+
+// Matches a "hh:mm:ss" time in a query parameter.
+var clockTimeRegex = regexp.MustCompile(`^(\d{1,6}):([0-5]\d):([0-5]\d)$`)
+
+// Matches any inline WebVTT tag, e.g. <c.color>, </c>, <v Speaker>, <00:00:01.000>
+var vttTagRegex = regexp.MustCompile(`<[^>]*>`)
+
+// Matches a WebVTT voice tag, capturing the speaker name, e.g. <v Speaker> or <v.loud Speaker>
+var vttVoiceRegex = regexp.MustCompile(`<v(?:\.[^\s>]*)?\s+([^>]+)>`)
+
+// Matches a "[Name]: text" speaker prefix, capturing the name and the remaining text
+var speakerPrefixRegex = regexp.MustCompile(`^\[([^\]]+)\]:\s*(.*)$`)
+
+// Splits a "[Name]: text" speaker prefix off the line text. Returns an empty speaker if there is no prefix.
+func splitSpeaker(text string) (speaker, rest string) {
+	m := speakerPrefixRegex.FindStringSubmatch(text)
+	if m == nil {
+		return "", text
+	}
+	return strings.TrimSpace(m[1]), m[2]
+}
+
+// Returns the format of the transcript input. If no format is declared, it is inferred from
+// whichever transcript field is populated, defaulting to SRT.
+func transcriptFormat(data *TranscriptInput) string {
+	if data.Format != "" {
+		return strings.ToLower(data.Format)
+	}
+	if data.SrtTranscript == "" && data.VttTranscript != "" {
+		return TranscriptFormatVTT
+	}
+	return TranscriptFormatSRT
+}
+
+// Matches a language code such as "en", "ja" or "zh-hant", after lowercasing.
+var langRegex = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]+)*$`)
+
+// Returns the language of the track in the transcript input, lowercased. Empty for a track without a language.
+func transcriptLang(data *TranscriptInput) string {
+	return storedLang(strings.ToLower(strings.TrimSpace(data.Lang)))
+}
+
+// Returns the lang stored for a track selected by lang. The track without a language is stored with an empty lang,
+// and selected with untaggedLang, since an empty lang selects the default track.
+func storedLang(lang string) string {
+	if lang == untaggedLang {
+		return ""
 	}
+	return lang
+}
+
+// Version of the SRT/VTT parsers. Bump this whenever parsing changes, so that re-uploading an unchanged file
+// is parsed again instead of being skipped as unchanged.
+const transcriptParserVersion = 2
+
+// Returns a hash of the transcript content of the input, ignoring its metadata.
+func transcriptContentHash(data *TranscriptInput) string {
+	format := transcriptFormat(data)
+	content := data.SrtTranscript
+	if format == TranscriptFormatVTT {
+		content = data.VttTranscript
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "v%d\x00%s\x00", transcriptParserVersion, format)
+	io.WriteString(h, content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Parses the transcript content of the input into a slice of TranscriptLine based on its format.
+func parseTranscriptLines(data *TranscriptInput) []TranscriptLine {
+	lines, _ := parseTranscript(data, false)
 	return lines
 }
 
+// Parses the transcript content of the input based on its format, and reports every block that was skipped.
+func parseTranscript(data *TranscriptInput, strict bool) ([]TranscriptLine, []RejectedBlock) {
+	if transcriptFormat(data) == TranscriptFormatVTT {
+		return parseVTT(data.VttTranscript, strict)
+	}
+	return parseSRT(data.SrtTranscript, strict)
+}
+
+// Parses the transcript content of the input without storing it, for strict uploads and dry runs.
+func validateTranscript(data *TranscriptInput) ValidationOutput {
+	lines, rejected := parseTranscript(data, true)
+	return ValidationOutput{
+		Valid:    len(rejected) == 0,
+		Lines:    len(lines),
+		Rejected: rejected,
+	}
+}
+
+// Parses raw WebVTT content into a slice of TranscriptLine. Invalid cues are skipped.
+func parseVTTForLines(vttContent string) []TranscriptLine {
+	lines, _ := parseVTT(vttContent, false)
+	return lines
+}
+
+// Parses raw WebVTT content into a slice of TranscriptLine, and reports every cue that was skipped.
+// The header and any NOTE, STYLE, or REGION blocks are skipped, cue identifiers and cue settings
+// are ignored, and inline tags (<c>, <v Name>, <i>, timestamps, etc.) are stripped from the text.
+// The speaker is taken from the first <v Name> tag, or a "[Name]:" prefix.
+// In strict mode, cues with an end time before the start are also rejected.
+func parseVTT(vttContent string, strict bool) ([]TranscriptLine, []RejectedBlock) {
+	// Normalize line endings, strip the BOM, and trim whitespace
+	vttContent = strings.ReplaceAll(vttContent, "\r\n", "\n")
+	vttContent = strings.ReplaceAll(vttContent, "\r", "\n")
+	vttContent = strings.TrimPrefix(vttContent, "\uFEFF")
+	vttContent = strings.TrimSpace(vttContent)
+
+	blocks := strings.Split(vttContent, "\n\n")
+	// Pre-allocate with a reasonable capacity
+	lines := make([]TranscriptLine, 0, len(blocks))
+	rejected := make([]RejectedBlock, 0)
+
+	index := 0 // 1-based position of the block, not counting extra blank lines
+	for i, block := range blocks {
+		block = strings.Trim(block, "\n")
+		if block == "" {
+			continue // Extra blank lines between blocks
+		}
+		index++
+		parts := strings.Split(block, "\n")
+
+		// The first block is the "WEBVTT" header
+		if i == 0 && strings.HasPrefix(parts[0], "WEBVTT") {
+			continue
+		}
+		// Comments, style sheets, and region definitions carry no transcript text
+		switch firstField(parts[0]) {
+		case "NOTE", "STYLE", "REGION":
+			continue
+		}
+
+		// parts[0] is the optional cue identifier
+		if !strings.Contains(parts[0], "-->") {
+			parts = parts[1:]
+		}
+		if len(parts) == 0 || !strings.Contains(parts[0], "-->") {
+			rejected = append(rejected, RejectedBlock{Index: index, Reason: "missing timing line", Raw: block})
+			continue // Invalid block
+		}
+
+		// parts[0] is the timing line (e.g., "00:00:01.000 --> 00:00:04.000 align:start")
+		// parts[1:] is the text content
+		startMs, endMs, ok := parseTimingLine(parts[0], strict)
+		if !ok {
+			rejected = append(rejected, RejectedBlock{Index: index, Reason: "invalid timestamp line", Raw: block})
+			continue // Invalid timestamp line
+		}
+
+		// Clean up text: strip inline tags, decode entities, and remove newlines within a single block
+		text := strings.Join(parts[1:], " ")
+		var speaker string
+		if m := vttVoiceRegex.FindStringSubmatch(text); m != nil {
+			speaker = html.UnescapeString(strings.TrimSpace(m[1]))
+		}
+		text = vttTagRegex.ReplaceAllString(text, "")
+		text = html.UnescapeString(text)
+		text = strings.Join(strings.Fields(text), " ")
+		if speaker == "" {
+			speaker, text = splitSpeaker(text)
+		}
+
+		if text == "" {
+			rejected = append(rejected, RejectedBlock{Index: index, Reason: "empty text", Raw: block})
+			continue
+		}
+		lines = append(lines, TranscriptLine{
+			Start:   formatTimestamp(startMs),
+			StartMs: startMs,
+			EndMs:   endMs,
+			Speaker: speaker,
+			Text:    text,
+		})
+	}
+	return lines, rejected
+}
+
+// Parses a cue timing line ("00:00:01,000 --> 00:00:04,000") into start and end milliseconds.
+// Anything after the end timestamp, such as WebVTT cue settings, is ignored.
+// In strict mode, an end time before the start is invalid.
+func parseTimingLine(timingLine string, strict bool) (startMs, endMs int64, ok bool) {
+	start, end, found := strings.Cut(timingLine, "-->")
+	if !found {
+		return 0, 0, false
+	}
+	startMs, ok = parseTimestampMs(strings.TrimSpace(start), strict)
+	if !ok {
+		return 0, 0, false
+	}
+	endMs, ok = parseTimestampMs(firstField(end), strict)
+	if !ok {
+		return 0, 0, false
+	}
+	if strict && endMs < startMs {
+		return 0, 0, false
+	}
+	return startMs, endMs, true
+}
+
+// Converts an SRT ("hh:mm:ss,ttt") or WebVTT ("hh:mm:ss.ttt" or "mm:ss.ttt") timestamp into milliseconds.
+// Unless strict, shorter fractions such as "hh:mm:ss,5" are read as fractions of a second.
+func parseTimestampMs(timestamp string, strict bool) (int64, bool) {
+	m := timestampRegex.FindStringSubmatch(timestamp)
+	if m == nil || (strict && len(m[4]) != 3) {
+		return 0, false
+	}
+	var hours int64
+	if m[1] != "" {
+		h, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		hours = h
+	}
+	minutes, _ := strconv.ParseInt(m[2], 10, 64)
+	seconds, _ := strconv.ParseInt(m[3], 10, 64)
+	millis, _ := strconv.ParseInt(m[4]+strings.Repeat("0", 3-len(m[4])), 10, 64)
+	return ((hours*60+minutes)*60+seconds)*1000 + millis, true
+}
+
+// Formats milliseconds as "hh:mm:ss", dropping the milliseconds.
+func formatTimestamp(ms int64) string {
+	totalSeconds := ms / 1000
+	return fmt.Sprintf("%02d:%02d:%02d", totalSeconds/3600, (totalSeconds/60)%60, totalSeconds%60)
+}
+
+// Parses a "hh:mm:ss" time, as returned by formatTimestamp, into milliseconds.
+func parseClockTime(value string) (int64, bool) {
+	m := clockTimeRegex.FindStringSubmatch(value)
+	if m == nil {
+		return 0, false
+	}
+	hours, _ := strconv.ParseInt(m[1], 10, 64)
+	minutes, _ := strconv.ParseInt(m[2], 10, 64)
+	seconds, _ := strconv.ParseInt(m[3], 10, 64)
+	return ((hours*60+minutes)*60 + seconds) * 1000, true
+}
+
+// Returns the end of the second at ms, exclusive. A "hh:mm:ss" upper bound includes the lines starting during that second.
+func endOfSecond(ms int64) int64 {
+	return ms/1000*1000 + 1000
+}
+
+// Returns the lines of a transcript, ordered by start time, that are in the window.
+func (w TranscriptWindow) slice(lines []TranscriptLine) []TranscriptLine {
+	if w.Around != nil {
+		// The line being said is the last one to start by then, or the first line if none has.
+		i, _ := slices.BinarySearchFunc(lines, *w.Around+1, func(line TranscriptLine, ms int64) int {
+			return cmp.Compare(line.StartMs, ms)
+		})
+		i = max(i-1, 0)
+		return lines[max(i-w.Lines, 0):min(i+w.Lines+1, len(lines))]
+	}
+
+	start, end := 0, len(lines)
+	if w.From != nil {
+		start, _ = slices.BinarySearchFunc(lines, *w.From, func(line TranscriptLine, ms int64) int {
+			return cmp.Compare(line.StartMs, ms)
+		})
+	}
+	if w.To != nil {
+		end, _ = slices.BinarySearchFunc(lines, endOfSecond(*w.To), func(line TranscriptLine, ms int64) int {
+			return cmp.Compare(line.StartMs, ms)
+		})
+	}
+	if start >= end {
+		return []TranscriptLine{}
+	}
+	return lines[start:end]
+}
+
+// Formats milliseconds as an SRT timestamp, "hh:mm:ss,mmm".
+func formatSRTTimestamp(ms int64) string {
+	return fmt.Sprintf("%s,%03d", formatTimestamp(ms), ms%1000)
+}
+
+// Builds SRT content from transcript lines, numbering the blocks in the given order.
+// Speakers are written as a "[Name]:" prefix.
+func formatSRT(lines []TranscriptLine) string {
+	var sb strings.Builder
+	for i, line := range lines {
+		text := line.Text
+		if line.Speaker != "" {
+			text = "[" + line.Speaker + "]: " + text
+		}
+		fmt.Fprintf(&sb, "%d\n%s --> %s\n%s\n\n", i+1, formatSRTTimestamp(line.StartMs), formatSRTTimestamp(line.EndMs), text)
+	}
+	return sb.String()
+}
+
+// Returns the first whitespace separated field of s, or an empty string if there is none.
+func firstField(s string) string {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
 // Parses query parameters and the authorized channel from the request.
 func parseQueryData(r *http.Request) QueryData {
 	q := r.URL.Query()
@@ -56,29 +399,123 @@ func parseQueryData(r *http.Request) QueryData {
 		authorizedChannel = ""
 	}
 
+	var nearDistance *int
+	if distance, err := strconv.Atoi(q.Get("near")); err == nil {
+		nearDistance = &distance
+	}
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	contextLimit, _ := strconv.Atoi(q.Get("contextLimit"))
+	contextOffset, _ := strconv.Atoi(q.Get("contextOffset"))
+	before, _ := strconv.Atoi(q.Get("before"))
+	after, _ := strconv.Atoi(q.Get("after"))
+
+	var langs []string
+	for _, lang := range q["lang"] {
+		langs = append(langs, strings.ToLower(strings.TrimSpace(lang)))
+	}
+
+	var facets []string
+	for _, value := range queryValues(q, "facets") {
+		for _, facet := range strings.Split(value, ",") {
+			if facet = strings.TrimSpace(facet); facet != "" && !slices.Contains(facets, facet) {
+				facets = append(facets, facet)
+			}
+		}
+	}
+
+	var fromOffset, toOffset *int64
+	if ms, ok := parseClockTime(q.Get("fromOffset")); ok {
+		fromOffset = &ms
+	}
+	if ms, ok := parseClockTime(q.Get("toOffset")); ok {
+		toOffset = &ms
+	}
+
 	return QueryData{
-		SearchText:        q.Get("searchText"),
-		MatchWholeWord:    q.Get("matchWholeWord") == "true",
-		Streamer:          q.Get("streamer"),
-		StreamTitle:       q.Get("streamTitle"),
-		FromDate:          q.Get("fromDate"),
-		ToDate:            q.Get("toDate"),
-		StreamTypes:       q["streamType"],
-		AuthorizedChannel: authorizedChannel,
+		SearchText:         q.Get("searchText"),
+		SearchMode:         q.Get("searchMode"),
+		MatchWholeWord:     q.Get("matchWholeWord") == "true",
+		NearDistance:       nearDistance,
+		Limit:              limit,
+		Cursor:             q.Get("cursor"),
+		Sort:               q.Get("sort"),
+		ContextLimit:       contextLimit,
+		ContextOffset:      contextOffset,
+		Snippet:            q.Get("snippet") == "true",
+		Facets:             facets,
+		Before:             before,
+		After:              after,
+		IDs:                queryValues(q, "id"),
+		Streamers:          queryValues(q, "streamer"),
+		ExcludeStreamers:   queryValues(q, "excludeStreamer"),
+		StreamTitles:       queryValues(q, "streamTitle"),
+		ExcludeTitles:      queryValues(q, "excludeTitle"),
+		FromDate:           q.Get("fromDate"),
+		ToDate:             q.Get("toDate"),
+		StreamTypes:        q["streamType"],
+		ExcludeStreamTypes: queryValues(q, "excludeStreamType"),
+		Speakers:           q["speaker"],
+		Langs:              langs,
+		FromOffset:         fromOffset,
+		ToOffset:           toOffset,
+		AuthorizedChannel:  authorizedChannel,
+	}
+}
+
+// Returns the values of a repeatable query parameter, leaving out empty ones.
+func queryValues(q url.Values, key string) []string {
+	var values []string
+	for _, value := range q[key] {
+		if value != "" {
+			values = append(values, value)
+		}
 	}
+	return values
 }
 
 // Dynamically builds the WHERE clause and arg list for filters.
 func buildFilterQuery(qParams *strings.Builder, sqlArgs *[]any, queryData QueryData) {
 	qParams.WriteString(" WHERE 1=1")
+	buildStreamFilterQuery(qParams, sqlArgs, queryData)
 
-	if queryData.Streamer != "" {
-		qParams.WriteString(" AND t.streamer = ?")
-		*sqlArgs = append(*sqlArgs, queryData.Streamer)
+	// Enforce Membership restriction
+	// If AuthorizedChannel is set, allow Members streams for that channel.
+	// Otherwise (or for other channels), exclude Members streams.
+	qParams.WriteString(" AND (t.stream_type != 'Members'")
+	if queryData.AuthorizedChannel != "" {
+		qParams.WriteString(" OR (t.stream_type = 'Members' AND t.streamer = ?)")
+		*sqlArgs = append(*sqlArgs, queryData.AuthorizedChannel)
+	}
+	qParams.WriteString(")")
+}
+
+// Dynamically builds the conditions and arg list for filters on the stream metadata (aliased t), without the membership restriction.
+// The conditions are appended to an existing WHERE clause.
+func buildStreamFilterQuery(qParams *strings.Builder, sqlArgs *[]any, queryData QueryData) {
+	if len(queryData.IDs) > 0 {
+		fmt.Fprintf(qParams, " AND t.id IN (%s)", appendPlaceholders(sqlArgs, queryData.IDs))
+	}
+	if len(queryData.Streamers) > 0 {
+		fmt.Fprintf(qParams, " AND t.streamer IN (%s)", appendPlaceholders(sqlArgs, queryData.Streamers))
 	}
-	if queryData.StreamTitle != "" {
-		qParams.WriteString(" AND t.title LIKE ?")
-		*sqlArgs = append(*sqlArgs, "%"+queryData.StreamTitle+"%")
+	if len(queryData.ExcludeStreamers) > 0 {
+		fmt.Fprintf(qParams, " AND t.streamer NOT IN (%s)", appendPlaceholders(sqlArgs, queryData.ExcludeStreamers))
+	}
+	if len(queryData.StreamTitles) > 0 {
+		// A title only needs to contain one of the values.
+		qParams.WriteString(" AND (")
+		for i, title := range queryData.StreamTitles {
+			if i > 0 {
+				qParams.WriteString(" OR ")
+			}
+			qParams.WriteString("t.title LIKE ?")
+			*sqlArgs = append(*sqlArgs, "%"+title+"%")
+		}
+		qParams.WriteString(")")
+	}
+	for _, title := range queryData.ExcludeTitles {
+		qParams.WriteString(" AND t.title NOT LIKE ?")
+		*sqlArgs = append(*sqlArgs, "%"+title+"%")
 	}
 	if queryData.FromDate != "" {
 		qParams.WriteString(" AND t.date >= ?")
@@ -89,29 +526,58 @@ func buildFilterQuery(qParams *strings.Builder, sqlArgs *[]any, queryData QueryD
 		*sqlArgs = append(*sqlArgs, queryData.ToDate)
 	}
 	if len(queryData.StreamTypes) > 0 {
-		var placeholders strings.Builder
-		for i, st := range queryData.StreamTypes {
-			if i > 0 {
-				placeholders.WriteString(", ")
-			}
-			placeholders.WriteString("?")
-			*sqlArgs = append(*sqlArgs, st) // Append to the pointer
+		fmt.Fprintf(qParams, " AND t.stream_type IN (%s)", appendPlaceholders(sqlArgs, queryData.StreamTypes))
+	}
+	if len(queryData.ExcludeStreamTypes) > 0 {
+		fmt.Fprintf(qParams, " AND t.stream_type NOT IN (%s)", appendPlaceholders(sqlArgs, queryData.ExcludeStreamTypes))
+	}
+}
+
+// Appends the values to the arg list, and returns a placeholder for each of them, separated by commas.
+func appendPlaceholders(sqlArgs *[]any, values []string) string {
+	var placeholders strings.Builder
+	for i, value := range values {
+		if i > 0 {
+			placeholders.WriteString(", ")
 		}
-		fmt.Fprintf(qParams, " AND t.stream_type IN (%s)", placeholders.String())
+		placeholders.WriteString("?")
+		*sqlArgs = append(*sqlArgs, value)
 	}
+	return placeholders.String()
+}
 
-	// Enforce Membership restriction
-	// If AuthorizedChannel is set, allow Members streams for that channel.
-	// Otherwise (or for other channels), exclude Members streams.
-	qParams.WriteString(" AND (t.stream_type != 'Members'")
-	if queryData.AuthorizedChannel != "" {
-		qParams.WriteString(" OR (t.stream_type = 'Members' AND t.streamer = ?)")
-		*sqlArgs = append(*sqlArgs, queryData.AuthorizedChannel)
+// Returns true if the query filters on the individual transcript lines, not just the transcripts.
+func hasLineFilters(queryData QueryData) bool {
+	return len(queryData.Speakers) > 0 || len(queryData.Langs) > 0 || queryData.FromOffset != nil || queryData.ToOffset != nil
+}
+
+// Dynamically builds the conditions and arg list for filters on the transcript lines (aliased tl).
+// Without langs, only the default track of each transcript is selected.
+// The conditions are appended to an existing WHERE clause.
+func buildLineFilterQuery(qParams *strings.Builder, sqlArgs *[]any, queryData QueryData) {
+	if len(queryData.Speakers) > 0 {
+		fmt.Fprintf(qParams, " AND tl.speaker COLLATE NOCASE IN (%s)", appendPlaceholders(sqlArgs, queryData.Speakers))
+	}
+	if len(queryData.Langs) > 0 {
+		langs := make([]string, len(queryData.Langs))
+		for i, lang := range queryData.Langs {
+			langs[i] = storedLang(lang)
+		}
+		fmt.Fprintf(qParams, " AND tl.lang IN (%s)", appendPlaceholders(sqlArgs, langs))
+	} else {
+		qParams.WriteString(defaultTrackFilter)
+	}
+	if queryData.FromOffset != nil {
+		qParams.WriteString(" AND tl.start_ms >= ?")
+		*sqlArgs = append(*sqlArgs, *queryData.FromOffset)
+	}
+	if queryData.ToOffset != nil {
+		qParams.WriteString(" AND tl.start_ms < ?")
+		*sqlArgs = append(*sqlArgs, endOfSecond(*queryData.ToOffset))
 	}
-	qParams.WriteString(")")
 }
 
-// Memoizes compiled regexes for performance.
+// Memoizes compiled regexes for performance. The regex matches any term of the search query that is not excluded.
 func (a *App) getRegex(searchText string, matchWholeWord bool) (*regexp.Regexp, error) {
 	key := fmt.Sprintf("%t:%s", matchWholeWord, searchText)
 
@@ -123,13 +589,12 @@ func (a *App) getRegex(searchText string, matchWholeWord bool) (*regexp.Regexp,
 		return re, nil
 	}
 
-	cleanSearchText := normalizeText(searchText)
-	reStr := regexp.QuoteMeta(cleanSearchText)
-	if matchWholeWord {
-		reStr = `\b` + reStr + `\b`
+	query, err := parseSearchQuery(searchText)
+	if err != nil {
+		return nil, err
 	}
 
-	newRe, err := regexp.Compile(reStr)
+	newRe, err := regexp.Compile(query.countPattern(matchWholeWord))
 	if err != nil {
 		return nil, err
 	}
@@ -141,36 +606,184 @@ func (a *App) getRegex(searchText string, matchWholeWord bool) (*regexp.Regexp,
 	return newRe, nil
 }
 
-// Formats a search string for FTS5.
-func buildFTSQuery(searchText string) string {
-	cleanText := normalizeText(searchText)
-	return `"` + cleanText + `"`
+// Returns a regex pattern matching the text as a whole word. Word boundaries are only added next to
+// ASCII letters and digits, since \b never matches next to CJK characters, which have no word boundaries.
+func wholeWordPattern(text string) string {
+	pattern := regexp.QuoteMeta(text)
+	if r, _ := utf8.DecodeRuneInString(text); isASCIIWordChar(r) {
+		pattern = `\b` + pattern
+	}
+	if r, _ := utf8.DecodeLastRuneInString(text); isASCIIWordChar(r) {
+		pattern += `\b`
+	}
+	return pattern
+}
+
+func isASCIIWordChar(r rune) bool {
+	return r == '_' || r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// Returns true for Japanese and Chinese characters: kana, CJK ideographs and the iteration marks.
+// Japanese and Chinese are written without spaces, so each of these characters is indexed as its own word,
+// and a word inside a sentence is searched as a phrase of its characters.
+// Text is split by script rather than by the lang of its track: tracks stored before languages existed have no lang,
+// and lines often mix scripts, such as Japanese quoted in an English track, so the lang doesn't say how a word is written.
+func isCJK(r rune) bool {
+	switch {
+	case r >= 0x3005 && r <= 0x3007: // 々〆〇
+		return true
+	case r >= 0x3040 && r <= 0x30FF: // Hiragana, Katakana
+		return true
+	case r >= 0x3400 && r <= 0x4DBF: // CJK Unified Ideographs Extension A
+		return true
+	case r >= 0x4E00 && r <= 0x9FFF: // CJK Unified Ideographs
+		return true
+	case r >= 0xF900 && r <= 0xFAFF: // CJK Compatibility Ideographs
+		return true
+	case r >= 0xFF66 && r <= 0xFF9F: // Halfwidth Katakana
+		return true
+	}
+	return false
+}
+
+// SQLite GLOB pattern matching clean text that has two CJK characters next to each other,
+// which normalizeText never produces. Used to find lines stored before CJK text was segmented.
+const unsegmentedCJKGlob = "*[々-〇぀-ヿ㐀-䶿一-鿿豈-﫿ｦ-ﾟ][々-〇぀-ヿ㐀-䶿一-鿿豈-﫿ｦ-ﾟ]*"
+
+// A word of a line, as byte offsets into the line.
+type textSpan struct {
+	start, end int
+}
+
+// Splits text into the words that normalizeText keeps. Words are separated by spaces and punctuation,
+// and every CJK character is a word of its own.
+func textSpans(s string) []textSpan {
+	var spans []textSpan
+	start := -1
+	for i, r := range s {
+		switch {
+		case unicode.IsSpace(r) || unicode.IsPunct(r):
+			if start >= 0 {
+				spans = append(spans, textSpan{start, i})
+				start = -1
+			}
+		case isCJK(r):
+			if start >= 0 {
+				spans = append(spans, textSpan{start, i})
+				start = -1
+			}
+			spans = append(spans, textSpan{i, i + utf8.RuneLen(r)})
+		default:
+			if start < 0 {
+				start = i
+			}
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, textSpan{start, len(s)})
+	}
+	return spans
 }
 
 // Cleans text for searching.
 func normalizeText(s string) string {
 	var b strings.Builder
 	b.Grow(len(s))
-	for _, r := range s {
-		if unicode.IsPunct(r) {
-			b.WriteRune(' ')
-		} else {
-			b.WriteRune(unicode.ToLower(r))
+	for i, span := range textSpans(s) {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(strings.ToLower(s[span.start:span.end]))
+	}
+	return b.String()
+}
+
+// Words kept on each side of the match by snippet=true.
+const snippetWordBuffer = 10
+
+// Returns the byte ranges of the original text matched by re. re is matched against the clean text of the line
+// and the matches are mapped back to the original words, unless matchOriginal is set.
+// A match starting or ending inside a word is mapped inside the original word when lowercasing didn't change its length.
+func matchRanges(originalText string, re *regexp.Regexp, matchOriginal bool) []textSpan {
+	var ranges []textSpan
+	if matchOriginal {
+		for _, m := range re.FindAllStringIndex(originalText, -1) {
+			ranges = append(ranges, textSpan{m[0], m[1]})
 		}
+		return ranges
 	}
-	return strings.Join(strings.Fields(b.String()), " ")
+
+	spans := textSpans(originalText)
+	clean := make([]textSpan, len(spans)) // Position of each word in the clean text
+	var b strings.Builder
+	for i, span := range spans {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		clean[i].start = b.Len()
+		b.WriteString(strings.ToLower(originalText[span.start:span.end]))
+		clean[i].end = b.Len()
+	}
+
+	for _, m := range re.FindAllStringIndex(b.String(), -1) {
+		first := slices.IndexFunc(clean, func(c textSpan) bool { return c.end > m[0] })
+		last := len(clean) - 1
+		for last >= 0 && clean[last].start >= m[1] {
+			last--
+		}
+		if first < 0 || last < first {
+			continue
+		}
+
+		start, end := spans[first].start, spans[last].end
+		if wordLen := spans[first].end - spans[first].start; clean[first].end-clean[first].start == wordLen {
+			start += max(0, m[0]-clean[first].start)
+		}
+		if wordLen := spans[last].end - spans[last].start; clean[last].end-clean[last].start == wordLen {
+			end = spans[last].start + min(m[1]-clean[last].start, wordLen)
+		}
+		ranges = append(ranges, textSpan{start, end})
+	}
+	return ranges
+}
+
+// Converts byte ranges of text to HighlightRanges.
+func highlightRanges(text string, ranges []textSpan) []HighlightRange {
+	highlights := make([]HighlightRange, 0, len(ranges))
+	for _, r := range ranges {
+		start := utf8.RuneCountInString(text[:r.start])
+		highlights = append(highlights, HighlightRange{Start: start, End: start + utf8.RuneCountInString(text[r.start:r.end])})
+	}
+	return highlights
+}
+
+// Returns a snippet of the line around the first of its matches, made by createSnippet.
+// The line is returned as is if it has no matches.
+func matchSnippet(line string, matches []textSpan) string {
+	if len(matches) == 0 {
+		return line
+	}
+	// The words of the first match, whole, are what createSnippet looks for.
+	start, end := matches[0].start, matches[0].end
+	for _, span := range textSpans(line) {
+		if span.start < matches[0].end && span.end > matches[0].start {
+			start, end = min(start, span.start), max(end, span.end)
+		}
+	}
+	return createSnippet(line, normalizeText(line), line[start:end], snippetWordBuffer)
 }
 
 // Finds the search text in the clean text, maps its word position to the original text,
-// and extracts a snippet with a word buffer.
+// and extracts a snippet with a word buffer. Each CJK character counts as a word.
 func createSnippet(originalText, cleanText, searchText string, wordBuffer int) string {
 	originalWords := strings.Fields(originalText)
+	spans := textSpans(originalText)
 	cleanWords := strings.Fields(cleanText)
 	cleanSearch := normalizeText(searchText)
 	cleanSearchWords := strings.Fields(cleanSearch)
 
 	// Basic safety checks
-	if len(cleanSearchWords) == 0 || len(originalWords) == 0 {
+	if len(cleanSearchWords) == 0 || len(spans) == 0 {
 		return originalText
 	}
 
@@ -199,30 +812,27 @@ func createSnippet(originalText, cleanText, searchText string, wordBuffer int) s
 		return originalText
 	}
 
-	// --- Estimate Index and Length in Original Text ---
-	// Use the clean index as the best guess for the start in originalWords
+	// --- Index and Length in Original Text ---
+	// The clean text is built from the same words as spans, so the indexes line up.
 	matchStartIndexOriginal := cleanMatchWordIndex
-	// Use the length of the *clean search phrase* as the best guess for match length
 	matchLengthOriginal := len(cleanSearchWords)
 
-	// --- Calculate Ideal Boundaries (Indices for originalWords) ---
+	// --- Calculate Ideal Boundaries (Indices for spans) ---
 	// Ideal start index: 'wordBuffer' words before the match starts
 	idealStart := matchStartIndexOriginal - wordBuffer
 	// Ideal end index: 'wordBuffer' words after the match ends
 	idealEnd := matchStartIndexOriginal + matchLengthOriginal + wordBuffer // end is the exclusive index
 
 	// --- Clamp Boundaries to Valid Slice Indices ---
-	// startClamped: must be >= 0
 	startClamped := max(0, idealStart)
-	// endClamped: must be <= len(originalWords)
-	endClamped := min(len(originalWords), idealEnd)
+	endClamped := min(len(spans), idealEnd)
 
 	// --- Final Sanity Check for Slice Validity ---
 	// Ensure startClamped is strictly less than endClamped
 	if startClamped >= endClamped {
 		// Attempt to fallback to just the estimated match words
 		startClamped = max(0, matchStartIndexOriginal)
-		endClamped = min(len(originalWords), matchStartIndexOriginal+matchLengthOriginal)
+		endClamped = min(len(spans), matchStartIndexOriginal+matchLengthOriginal)
 
 		// If even this is invalid (e.g., zero length match somehow?), return original
 		if startClamped >= endClamped {
@@ -230,19 +840,34 @@ func createSnippet(originalText, cleanText, searchText string, wordBuffer int) s
 		}
 	}
 
-	// --- Slice and Join ---
-	snippetWords := originalWords[startClamped:endClamped]
-	snippet := strings.Join(snippetWords, " ")
+	// --- Slice the Original Text ---
+	// Widen the slice to keep punctuation attached to the first and last words, stopping at spaces and CJK characters.
+	start := spans[startClamped].start
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(originalText[:start])
+		if unicode.IsSpace(r) || isCJK(r) {
+			break
+		}
+		start -= size
+	}
+	end := spans[endClamped-1].end
+	for end < len(originalText) {
+		r, size := utf8.DecodeRuneInString(originalText[end:])
+		if unicode.IsSpace(r) || isCJK(r) {
+			break
+		}
+		end += size
+	}
+	snippet := strings.Join(strings.Fields(originalText[start:end]), " ")
 
 	// --- Add Ellipsis ---
 	prefix := ""
 	suffix := ""
-	// Add prefix ellipsis if the actual start is later than the ideal start (meaning we trimmed the beginning)
-	if startClamped > 0 { // More precise: check if idealStart was < 0 and startClamped became 0
+	// Add an ellipsis on each side where part of the original text was cut off
+	if strings.TrimSpace(originalText[:start]) != "" {
 		prefix = "___ "
 	}
-	// Add suffix ellipsis if the actual end is earlier than the ideal end (meaning we trimmed the end)
-	if endClamped < len(originalWords) { // More precise: check if idealEnd was > len(originalWords) and endClamped got capped
+	if strings.TrimSpace(originalText[end:]) != "" {
 		suffix = " ___"
 	}
 
@@ -1,8 +1,11 @@
 package internal
 
 import (
+	"fmt"
+	"net/http/httptest"
 	"reflect"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
@@ -14,19 +17,27 @@ func TestParseSRT(t *testing.T) {
 		input    string
 		expected []TranscriptLine
 	}{
+		{
+			name:  "Speaker prefix",
+			input: "1\n00:00:01,000 --> 00:00:04,000\n[Fauna]: Hello\nworld\n\n2\n00:00:05,000 --> 00:00:08,000\n[Music] not a speaker\n\n3\n00:00:09,000 --> 00:00:10,000\n[Fauna]:",
+			expected: []TranscriptLine{
+				{Start: "00:00:01", StartMs: 1000, EndMs: 4000, Speaker: "Fauna", Text: "Hello world"},
+				{Start: "00:00:05", StartMs: 5000, EndMs: 8000, Text: "[Music] not a speaker"},
+			},
+		},
 		{
 			name:  "Basic SRT",
 			input: "1\n00:00:01,000 --> 00:00:04,000\nHello world\n\n2\n00:00:05,000 --> 00:00:08,000\nNext line",
 			expected: []TranscriptLine{
-				{Start: "00:00:01", Text: "Hello world"},
-				{Start: "00:00:05", Text: "Next line"},
+				{Start: "00:00:01", StartMs: 1000, EndMs: 4000, Text: "Hello world"},
+				{Start: "00:00:05", StartMs: 5000, EndMs: 8000, Text: "Next line"},
 			},
 		},
 		{
 			name:  "SRT with multiline text",
 			input: "1\n00:00:01,000 --> 00:00:04,000\nHello\nworld\n\n",
 			expected: []TranscriptLine{
-				{Start: "00:00:01", Text: "Hello world"},
+				{Start: "00:00:01", StartMs: 1000, EndMs: 4000, Text: "Hello world"},
 			},
 		},
 		{
@@ -43,10 +54,37 @@ func TestParseSRT(t *testing.T) {
 			name:  "Mixed valid and invalid blocks",
 			input: "1\n00:00:01,000 --> 00:00:04,000\nGood\n\n2\nBAD --> BAD\nBad\n\n3\n00:00:05,000 --> 00:00:08,000\nAlso Good",
 			expected: []TranscriptLine{
-				{Start: "00:00:01", Text: "Good"},
-				{Start: "00:00:05", Text: "Also Good"},
+				{Start: "00:00:01", StartMs: 1000, EndMs: 4000, Text: "Good"},
+				{Start: "00:00:05", StartMs: 5000, EndMs: 8000, Text: "Also Good"},
+			},
+		},
+		{
+			name:  "Milliseconds preserved",
+			input: "1\n00:00:01,250 --> 00:00:03,999\nPrecise\n\n2\n01:02:03,004 --> 01:02:05,100\nLater",
+			expected: []TranscriptLine{
+				{Start: "00:00:01", StartMs: 1250, EndMs: 3999, Text: "Precise"},
+				{Start: "01:02:03", StartMs: 3723004, EndMs: 3725100, Text: "Later"},
+			},
+		},
+		{
+			name:  "Lenient index and timing",
+			input: "A\n00:00:02,000 --> 00:00:01,000\nKept",
+			expected: []TranscriptLine{
+				{Start: "00:00:02", StartMs: 2000, EndMs: 1000, Text: "Kept"},
+			},
+		},
+		{
+			name:  "Short milliseconds",
+			input: "1\n00:00:01,5 --> 00:00:02.25\nKept",
+			expected: []TranscriptLine{
+				{Start: "00:00:01", StartMs: 1500, EndMs: 2250, Text: "Kept"},
 			},
 		},
+		{
+			name:     "Bad end timestamp ignored",
+			input:    "1\n00:00:01,000 --> BAD\nHello\n\n",
+			expected: []TranscriptLine{},
+		},
 	}
 
 	for _, tt := range tests {
@@ -63,6 +101,305 @@ func TestParseSRT(t *testing.T) {
 	}
 }
 
+func TestParseVTT(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []TranscriptLine
+	}{
+		{
+			name:  "Basic VTT",
+			input: "WEBVTT\n\n00:00:01.000 --> 00:00:04.000\nHello world\n\n00:00:05.000 --> 00:00:08.000\nNext line",
+			expected: []TranscriptLine{
+				{Start: "00:00:01", StartMs: 1000, EndMs: 4000, Text: "Hello world"},
+				{Start: "00:00:05", StartMs: 5000, EndMs: 8000, Text: "Next line"},
+			},
+		},
+		{
+			name:  "Header with metadata and cue identifiers",
+			input: "WEBVTT - Some title\nKind: captions\nLanguage: en\n\n1\n00:00:01.000 --> 00:00:04.000\nHello\n\nintro\n00:00:05.000 --> 00:00:08.000\nWorld",
+			expected: []TranscriptLine{
+				{Start: "00:00:01", StartMs: 1000, EndMs: 4000, Text: "Hello"},
+				{Start: "00:00:05", StartMs: 5000, EndMs: 8000, Text: "World"},
+			},
+		},
+		{
+			name:  "Short timestamps and cue settings",
+			input: "WEBVTT\n\n01:02.500 --> 01:04.000 align:start position:0%\nShort form",
+			expected: []TranscriptLine{
+				{Start: "00:01:02", StartMs: 62500, EndMs: 64000, Text: "Short form"},
+			},
+		},
+		{
+			name:  "NOTE, STYLE and REGION blocks skipped",
+			input: "WEBVTT\n\nSTYLE\n::cue { color: red }\n\nREGION\nid:fred\n\nNOTE this is a comment\nspanning lines\n\n00:00:01.000 --> 00:00:02.000\nKept",
+			expected: []TranscriptLine{
+				{Start: "00:00:01", StartMs: 1000, EndMs: 2000, Text: "Kept"},
+			},
+		},
+		{
+			name:  "Inline tags and entities stripped",
+			input: "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\n<v Speaker>Hello</v> <c.yellow>big</c><00:00:01.500> <i>world</i> &amp; friends",
+			expected: []TranscriptLine{
+				{Start: "00:00:01", StartMs: 1000, EndMs: 2000, Speaker: "Speaker", Text: "Hello big world & friends"},
+			},
+		},
+		{
+			name:  "Speakers",
+			input: "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\n<v.loud Mori Calliope>Hi</v>\n\n00:00:03.000 --> 00:00:04.000\n[Kiara]: Hello\n\n00:00:05.000 --> 00:00:06.000\n<v A>One</v> <v B>Two</v>",
+			expected: []TranscriptLine{
+				{Start: "00:00:01", StartMs: 1000, EndMs: 2000, Speaker: "Mori Calliope", Text: "Hi"},
+				{Start: "00:00:03", StartMs: 3000, EndMs: 4000, Speaker: "Kiara", Text: "Hello"},
+				{Start: "00:00:05", StartMs: 5000, EndMs: 6000, Speaker: "A", Text: "One Two"},
+			},
+		},
+		{
+			name:  "Multiline text and CRLF",
+			input: "WEBVTT\r\n\r\n00:00:01.000 --> 00:00:02.000\r\nHello\r\nworld\r\n\r\n\r\n00:00:03.000 --> 00:00:04.000\r\nAgain",
+			expected: []TranscriptLine{
+				{Start: "00:00:01", StartMs: 1000, EndMs: 2000, Text: "Hello world"},
+				{Start: "00:00:03", StartMs: 3000, EndMs: 4000, Text: "Again"},
+			},
+		},
+		{
+			name:  "Long hours",
+			input: "WEBVTT\n\n101:00:01.000 --> 101:00:02.000\nLate",
+			expected: []TranscriptLine{
+				{Start: "101:00:01", StartMs: 363601000, EndMs: 363602000, Text: "Late"},
+			},
+		},
+		{
+			name:     "Empty input",
+			input:    "",
+			expected: []TranscriptLine{},
+		},
+		{
+			name:  "Mixed valid and invalid blocks",
+			input: "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nGood\n\nBAD --> BAD\nBad\n\n00:00:03.000 --> 00:00:04.000\n<c></c>\n\n00:00:05.000 --> 00:00:06.000\nAlso Good",
+			expected: []TranscriptLine{
+				{Start: "00:00:01", StartMs: 1000, EndMs: 2000, Text: "Good"},
+				{Start: "00:00:05", StartMs: 5000, EndMs: 6000, Text: "Also Good"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseVTTForLines(tt.input)
+			if len(got) == 0 && len(tt.expected) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseVTTForLines() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseTranscriptLines_Format(t *testing.T) {
+	srt := "1\n00:00:01,000 --> 00:00:04,000\nHello world"
+	vtt := "WEBVTT\n\n00:00:01.000 --> 00:00:04.000\nHello world"
+	want := []TranscriptLine{{Start: "00:00:01", StartMs: 1000, EndMs: 4000, Text: "Hello world"}}
+
+	inputs := map[string]TranscriptInput{
+		"SRT default":         {SrtTranscript: srt},
+		"SRT declared":        {Format: "srt", SrtTranscript: srt},
+		"VTT inferred":        {VttTranscript: vtt},
+		"VTT declared":        {Format: "vtt", VttTranscript: vtt},
+		"VTT declared (case)": {Format: "VTT", VttTranscript: vtt},
+	}
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			got := parseTranscriptLines(&input)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("parseTranscriptLines() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateTranscript(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        TranscriptInput
+		wantLines    int
+		wantRejected []RejectedBlock
+	}{
+		{
+			name:         "Valid SRT",
+			input:        TranscriptInput{SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nGood\n\n\n\n2\n00:00:03,000 --> 00:00:04,000\nAlso good"},
+			wantLines:    2,
+			wantRejected: []RejectedBlock{},
+		},
+		{
+			name:      "Invalid SRT blocks",
+			input:     TranscriptInput{SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nGood\n\n2\nBAD --> BAD\nBad\n\n3\n00:00:05,000\n\n4\n00:00:06,000 --> 00:00:07,000\n   \n\n5\n00:00:08,000 --> 00:00:09,000\nEnd"},
+			wantLines: 2,
+			wantRejected: []RejectedBlock{
+				{Index: 2, Reason: "invalid timestamp line", Raw: "2\nBAD --> BAD\nBad"},
+				{Index: 3, Reason: "expected an index, a timestamp line, and text", Raw: "3\n00:00:05,000"},
+				{Index: 4, Reason: "empty text", Raw: "4\n00:00:06,000 --> 00:00:07,000\n   "},
+			},
+		},
+		{
+			name:      "Strict SRT blocks",
+			input:     TranscriptInput{SrtTranscript: "1\n00:00:01,000 --> 00:00:02,000\nGood\n\n\n\nA\n00:00:03,000 --> 00:00:04,000\nBad index\n\n3\n00:00:06,000 --> 00:00:05,000\nBackwards"},
+			wantLines: 1,
+			wantRejected: []RejectedBlock{
+				{Index: 2, Reason: "invalid index", Raw: "A\n00:00:03,000 --> 00:00:04,000\nBad index"},
+				{Index: 3, Reason: "invalid timestamp line", Raw: "3\n00:00:06,000 --> 00:00:05,000\nBackwards"},
+			},
+		},
+		{
+			name:      "Strict VTT cues",
+			input:     TranscriptInput{Format: "vtt", VttTranscript: "WEBVTT\n\n\n\n00:00:02.000 --> 00:00:01.000\nBackwards"},
+			wantLines: 0,
+			wantRejected: []RejectedBlock{
+				{Index: 2, Reason: "invalid timestamp line", Raw: "00:00:02.000 --> 00:00:01.000\nBackwards"},
+			},
+		},
+		{
+			name:         "Valid VTT",
+			input:        TranscriptInput{Format: "vtt", VttTranscript: "WEBVTT\n\nNOTE ignored\n\n00:00:01.000 --> 00:00:02.000\nGood"},
+			wantLines:    1,
+			wantRejected: []RejectedBlock{},
+		},
+		{
+			name:      "Invalid VTT cues",
+			input:     TranscriptInput{Format: "vtt", VttTranscript: "WEBVTT\n\nid\nno timing\n\n00:00:01 --> 00:00:02\nBad\n\n00:00:03.000 --> 00:00:04.000\n<c></c>"},
+			wantLines: 0,
+			wantRejected: []RejectedBlock{
+				{Index: 2, Reason: "missing timing line", Raw: "id\nno timing"},
+				{Index: 3, Reason: "invalid timestamp line", Raw: "00:00:01 --> 00:00:02\nBad"},
+				{Index: 4, Reason: "empty text", Raw: "00:00:03.000 --> 00:00:04.000\n<c></c>"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validateTranscript(&tt.input)
+			if got.Valid != (len(tt.wantRejected) == 0) {
+				t.Errorf("Valid = %t, want %t", got.Valid, len(tt.wantRejected) == 0)
+			}
+			if got.Lines != tt.wantLines {
+				t.Errorf("Lines = %d, want %d", got.Lines, tt.wantLines)
+			}
+			if !reflect.DeepEqual(got.Rejected, tt.wantRejected) {
+				t.Errorf("Rejected = %+v, want %+v", got.Rejected, tt.wantRejected)
+			}
+		})
+	}
+}
+
+func TestParseTimestampMs(t *testing.T) {
+	tests := []struct {
+		input  string
+		strict bool
+		want   int64
+		wantOk bool
+	}{
+		{"00:00:00,000", true, 0, true},
+		{"00:00:01,500", true, 1500, true},
+		{"01:02:03,004", true, 3723004, true},
+		{"1:02:03,004", true, 3723004, true},
+		{"100:00:00,000", true, 360000000, true},
+		{"00:00:01.500", true, 1500, true},
+		{"02:03.004", true, 123004, true},
+		{"00:00:01,5", false, 1500, true},
+		{"00:00:01.50", false, 1500, true},
+		{"00:00:01,05", false, 1050, true},
+		{"00:00:01,5", true, 0, false},
+		{"00:00:01,5000", false, 0, false},
+		{"00:60:00,000", false, 0, false},
+		{"00:00:01", false, 0, false},
+		{"BAD", false, 0, false},
+		{"", false, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, ok := parseTimestampMs(tt.input, tt.strict)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("parseTimestampMs(%q) = %d, %t, want %d, %t", tt.input, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	tests := []struct {
+		input int64
+		want  string
+	}{
+		{0, "00:00:00"},
+		{1999, "00:00:01"},
+		{3723004, "01:02:03"},
+		{363601000, "101:00:01"},
+	}
+
+	for _, tt := range tests {
+		if got := formatTimestamp(tt.input); got != tt.want {
+			t.Errorf("formatTimestamp(%d) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseClockTime(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+		ok    bool
+	}{
+		{"00:00:00", 0, true},
+		{"01:02:03", 3723000, true},
+		{"123:00:01", 442801000, true},
+		{"00:60:00", 0, false},
+		{"1:2:3", 0, false},
+		{"00:01", 0, false},
+		{"+1:00:00", 0, false},
+		{"00:00:01,000", 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := parseClockTime(tt.value); got != tt.want || ok != tt.ok {
+			t.Errorf("parseClockTime(%q) = %d, %t, want %d, %t", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTranscriptWindow(t *testing.T) {
+	var lines []TranscriptLine
+	for i, ms := range []int64{1000, 2500, 2900, 4000, 6000} {
+		lines = append(lines, TranscriptLine{ID: fmt.Sprintf("%d", i), StartMs: ms})
+	}
+	ms := func(v int64) *int64 { return &v }
+
+	tests := []struct {
+		name   string
+		window TranscriptWindow
+		want   []string
+	}{
+		{"All", TranscriptWindow{}, []string{"0", "1", "2", "3", "4"}},
+		{"From", TranscriptWindow{From: ms(2000)}, []string{"1", "2", "3", "4"}},
+		{"To whole second", TranscriptWindow{To: ms(2000)}, []string{"0", "1", "2"}},
+		{"From and to", TranscriptWindow{From: ms(3000), To: ms(4000)}, []string{"3"}},
+		{"Empty", TranscriptWindow{From: ms(7000)}, []string{}},
+		{"Around", TranscriptWindow{Around: ms(3000), Lines: 1}, []string{"1", "2", "3"}},
+		{"Around start of line", TranscriptWindow{Around: ms(4000), Lines: 1}, []string{"2", "3", "4"}},
+		{"Around before first line", TranscriptWindow{Around: ms(0), Lines: 2}, []string{"0", "1", "2"}},
+		{"Around after last line", TranscriptWindow{Around: ms(60000), Lines: 0}, []string{"4"}},
+	}
+	for _, tt := range tests {
+		got := []string{}
+		for _, line := range tt.window.slice(lines) {
+			got = append(got, line.ID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got lines %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
 func TestNormalizeText(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -73,6 +410,10 @@ func TestNormalizeText(t *testing.T) {
 		{"  Spaces  ", "spaces"},
 		{"Mixed CASE and Punc.!", "mixed case and punc"},
 		{"New\nLines", "new lines"},
+		{"猫が好きです。", "猫 が 好 き で す"},
+		{"今日はStreamを見た", "今 日 は stream を 見 た"},
+		{"我喜欢猫！", "我 喜 欢 猫"},
+		{"ラーメン", "ラ ー メ ン"},
 	}
 
 	for _, tt := range tests {
@@ -192,6 +533,75 @@ func TestCreateSnippet(t *testing.T) {
 	}
 }
 
+func TestCreateSnippet_CJK(t *testing.T) {
+	tests := []struct {
+		name       string
+		original   string
+		searchText string
+		wordBuffer int
+		want       string
+	}{
+		{"Japanese middle", "昨日は猫が好きだと言った", "好き", 2, "___ 猫が好きだと ___"},
+		{"Japanese punctuation", "はい。「ラーメン」を食べた", "ラーメン", 1, "___ い。「ラーメン」を ___"},
+		{"Chinese end", "我非常喜欢猫", "喜欢猫", 1, "___ 常喜欢猫"},
+		{"Mixed scripts", "今日はMinecraftをやります", "minecraft", 1, "___ はMinecraftを ___"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := createSnippet(tt.original, normalizeText(tt.original), tt.searchText, tt.wordBuffer)
+			if got != tt.want {
+				t.Errorf("createSnippet() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchRanges(t *testing.T) {
+	tests := []struct {
+		name          string
+		line          string
+		pattern       string
+		matchOriginal bool
+		want          []HighlightRange
+	}{
+		{"Punctuation", "Hello, World! The WORLD.", `world`, false, []HighlightRange{{7, 12}, {18, 23}}},
+		{"Inside a word", "Concatenate", `cat`, false, []HighlightRange{{3, 6}}},
+		{"Phrase across punctuation", "rock-n-roll!", `rock n roll`, false, []HighlightRange{{0, 11}}},
+		{"CJK", "猫が好きです", `好 き`, false, []HighlightRange{{2, 4}}},
+		{"Multibyte before the match", "🎉 pog!", `pog`, false, []HighlightRange{{2, 5}}},
+		{"Lowercase changes the length", "İstanbul", `stan`, false, []HighlightRange{{0, 8}}},
+		{"Original text", "Waaah, waah", `(?i)wa+h`, true, []HighlightRange{{0, 5}, {7, 11}}},
+		{"No match", "nothing here", `pog`, false, []HighlightRange{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := highlightRanges(tt.line, matchRanges(tt.line, regexp.MustCompile(tt.pattern), tt.matchOriginal))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("highlights of %q in %q = %v, want %v", tt.pattern, tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchSnippet(t *testing.T) {
+	line := "one two three four five six seven eight nine ten eleven twelve Minecraft! thirteen fourteen fifteen sixteen seventeen eighteen nineteen twenty twenty-one twenty-two"
+	re := regexp.MustCompile(`minecraft`)
+
+	snippet := matchSnippet(line, matchRanges(line, re, false))
+	want := "___ three four five six seven eight nine ten eleven twelve Minecraft! thirteen fourteen fifteen sixteen seventeen eighteen nineteen twenty twenty-one ___"
+	if snippet != want {
+		t.Errorf("matchSnippet() = %q, want %q", snippet, want)
+	}
+	if got := highlightRanges(snippet, matchRanges(snippet, re, false)); !slices.Equal(got, []HighlightRange{{59, 68}}) {
+		t.Errorf("Unexpected highlights in the snippet: %v", got)
+	}
+	if got := matchSnippet("no match", nil); got != "no match" {
+		t.Errorf("Expected the line without matches as is, got %q", got)
+	}
+}
+
 func TestBuildFilterQuery(t *testing.T) {
 	// Tests L93 (comma handling)
 	var qParams strings.Builder
@@ -213,6 +623,20 @@ func TestBuildFilterQuery(t *testing.T) {
 	}
 }
 
+func TestQueryValues(t *testing.T) {
+	req := httptest.NewRequest("GET", "/transcripts?streamer=A&streamer=&streamer=B&excludeTitle=karaoke&excludeStreamType=Short", nil)
+	queryData := parseQueryData(req)
+	if !slices.Equal(queryData.Streamers, []string{"A", "B"}) {
+		t.Errorf("Streamers = %v, want [A B]", queryData.Streamers)
+	}
+	if !slices.Equal(queryData.ExcludeTitles, []string{"karaoke"}) || !slices.Equal(queryData.ExcludeStreamTypes, []string{"Short"}) {
+		t.Errorf("Unexpected exclusions: %+v", queryData)
+	}
+	if queryData.StreamTitles != nil || queryData.ExcludeStreamers != nil {
+		t.Errorf("Expected no title or excluded streamer filters, got %+v", queryData)
+	}
+}
+
 func TestGetRegex(t *testing.T) {
 	app := &App{
 		regexCache:   make(map[string]*regexp.Regexp),
@@ -242,3 +666,27 @@ func TestGetRegex(t *testing.T) {
 		t.Error("Should match 'food' without whole word")
 	}
 }
+
+func TestGetRegex_CJK(t *testing.T) {
+	app := &App{
+		regexCache:   make(map[string]*regexp.Regexp),
+		regexCacheMu: sync.Mutex{},
+	}
+
+	// Word boundaries are not used next to CJK characters, which have none.
+	re, err := app.getRegex("好き", true)
+	if err != nil {
+		t.Fatalf("getRegex failed: %v", err)
+	}
+	if n := len(re.FindAllStringIndex(normalizeText("猫が好き、犬も好き"), -1)); n != 2 {
+		t.Errorf("Expected 2 matches, got %d", n)
+	}
+
+	re, err = app.getRegex("を見", true)
+	if err != nil {
+		t.Fatalf("getRegex failed: %v", err)
+	}
+	if !re.MatchString(normalizeText("streamを見た")) {
+		t.Error("Should match inside a mixed script line")
+	}
+}
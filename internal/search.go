@@ -0,0 +1,1110 @@
+package internal
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Operators of the search query language. They are only recognized in upper case,
+// so the words "and", "or" and "near" can still be searched for.
+const (
+	searchOperatorAnd  = "AND"
+	searchOperatorOr   = "OR"
+	searchOperatorNear = "NEAR"
+)
+
+// Number of words allowed between the two sides of a NEAR, when neither the query nor the near parameter sets it.
+const defaultNearDistance = 10
+
+// Largest number of words allowed between the two sides of a NEAR.
+const maxNearDistance = 100
+
+var errNoSearchTerms = errors.New("search text has no words to search for")
+var errOnlyExcludedTerms = errors.New("every part of the search needs at least one term that is not excluded")
+var errNearMissingTerm = errors.New("NEAR needs a word or phrase that is not excluded on each side")
+var errNearChain = errors.New("NEAR can only join two words or phrases")
+var errDanglingOperator = errors.New("AND and OR need a word or phrase on each side")
+var errNearDistance = fmt.Errorf("NEAR distance must be a number from 0 to %d", maxNearDistance)
+
+// searchTerm is a word or phrase of a search query, normalized the same way as the clean text of the lines.
+type searchTerm struct {
+	Text    string // Clean words separated by single spaces
+	Prefix  bool   // The last word matches any word starting with it
+	Exclude bool   // Lines containing the term do not match
+
+	// Set by expandFuzzyTerms in fuzzy mode. The term matches any of them instead of Text.
+	Variants []string
+
+	// Set instead of Text for two terms joined by NEAR.
+	Near     []searchTerm
+	Distance int // Words allowed between the two terms, or -1 to use the default distance
+}
+
+// searchQuery is a parsed search text. A line matches if it matches every term of any one group.
+//
+// The query language is:
+//   - Words next to each other are searched as a phrase, as are words in double quotes.
+//   - Phrases separated by AND, or by quotes, must all be in the line.
+//   - OR separates alternatives.
+//   - AND and OR need a word or phrase on each side.
+//   - A word or quoted phrase starting with - must not be in the line.
+//   - A word or quoted phrase ending with * matches any word starting with its last word.
+//   - Two phrases joined by NEAR, or NEAR/N, must be within N words of each other, in either order.
+//     The two sides can be in the line or in the line before or after it.
+type searchQuery struct {
+	Groups [][]searchTerm
+}
+
+// searchQueryParser holds the state of parseSearchQuery.
+type searchQueryParser struct {
+	query        searchQuery
+	group        []searchTerm
+	phrase       []string // Bare words of the phrase being read
+	phrasePrefix bool
+	nearPending  bool // NEAR was read and is waiting for the term on its right
+	nearDistance int
+	termRead     bool // A word or phrase was read since the start, or since the last AND or OR
+	dangling     bool // An AND or OR is missing a word or phrase on one side
+	err          error
+}
+
+// Parses search text into a searchQuery. Returns an error if nothing can be searched.
+func parseSearchQuery(searchText string) (searchQuery, error) {
+	p := &searchQueryParser{}
+
+	runes := []rune(searchText)
+	for i := 0; i < len(runes) && p.err == nil; {
+		r := runes[i]
+		if unicode.IsSpace(r) {
+			i++
+			continue
+		}
+
+		exclude := false
+		if r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			exclude = true
+			i++
+		}
+
+		// Quoted phrase, up to the closing quote or the end of the text.
+		if runes[i] == '"' {
+			p.termRead = true
+			p.endPhrase()
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			text := string(runes[i+1 : min(end, len(runes))])
+			i = end + 1
+			prefix := false
+			if i < len(runes) && runes[i] == '*' {
+				prefix = true
+				for i < len(runes) && runes[i] == '*' {
+					i++
+				}
+			}
+			p.addTerm(text, prefix, exclude)
+			continue
+		}
+
+		// Bare word, up to the next space or quote.
+		end := i
+		for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '"' {
+			end++
+		}
+		word := string(runes[i:end])
+		i = end
+
+		switch {
+		case exclude:
+			p.termRead = true
+			p.endPhrase()
+			p.addTerm(strings.TrimRight(word, "*"), strings.HasSuffix(word, "*"), true)
+		case word == searchOperatorOr:
+			p.endOperand()
+			p.endGroup()
+		case word == searchOperatorAnd:
+			p.endOperand()
+			p.endPhrase()
+		case word == searchOperatorNear || strings.HasPrefix(word, searchOperatorNear+"/"):
+			p.startNear(word)
+		default:
+			p.termRead = true
+			p.phrase = append(p.phrase, strings.TrimRight(word, "*"))
+			if strings.HasSuffix(word, "*") {
+				p.phrasePrefix = true
+				p.endPhrase()
+			}
+		}
+	}
+	p.endGroup()
+	if !p.termRead {
+		p.dangling = true // The text ends with an AND or OR
+	}
+
+	if p.err != nil {
+		return searchQuery{}, p.err
+	}
+	if len(p.query.Groups) == 0 {
+		return searchQuery{}, errNoSearchTerms
+	}
+	if p.dangling {
+		return searchQuery{}, errDanglingOperator
+	}
+	for _, group := range p.query.Groups {
+		if !slices.ContainsFunc(group, func(term searchTerm) bool { return !term.Exclude }) {
+			return searchQuery{}, errOnlyExcludedTerms
+		}
+	}
+	return p.query, nil
+}
+
+// Reads an AND or OR, which needs a word or phrase since the start or the previous AND or OR.
+func (p *searchQueryParser) endOperand() {
+	if !p.termRead {
+		p.dangling = true
+	}
+	p.termRead = false
+}
+
+func (p *searchQueryParser) endPhrase() {
+	if len(p.phrase) > 0 {
+		p.addTerm(strings.Join(p.phrase, " "), p.phrasePrefix, false)
+	}
+	p.phrase = nil
+	p.phrasePrefix = false
+}
+
+func (p *searchQueryParser) endGroup() {
+	p.endPhrase()
+	if p.nearPending {
+		p.fail(errNearMissingTerm)
+	}
+	if len(p.group) > 0 {
+		p.query.Groups = append(p.query.Groups, p.group)
+	}
+	p.group = nil
+}
+
+// Reads a NEAR or NEAR/N operator. The last term of the group becomes its left side.
+func (p *searchQueryParser) startNear(word string) {
+	p.endPhrase()
+	distance := -1
+	if n, ok := strings.CutPrefix(word, searchOperatorNear+"/"); ok {
+		d, err := strconv.Atoi(n)
+		if err != nil || d < 0 || d > maxNearDistance {
+			p.fail(errNearDistance)
+			return
+		}
+		distance = d
+	}
+
+	switch {
+	case p.nearPending || len(p.group) == 0 || p.group[len(p.group)-1].Exclude:
+		p.fail(errNearMissingTerm)
+	case len(p.group[len(p.group)-1].Near) > 0:
+		p.fail(errNearChain)
+	default:
+		p.nearPending = true
+		p.nearDistance = distance
+	}
+}
+
+// Normalizes the term text and adds it to the group, unless nothing is left of it.
+// If a NEAR is waiting for its right side, the term is joined to the previous one.
+func (p *searchQueryParser) addTerm(text string, prefix, exclude bool) {
+	clean := normalizeText(text)
+	if clean == "" {
+		return
+	}
+	term := searchTerm{Text: clean, Prefix: prefix, Exclude: exclude}
+	if !p.nearPending {
+		p.group = append(p.group, term)
+		return
+	}
+
+	p.nearPending = false
+	if exclude {
+		p.fail(errNearMissingTerm)
+		return
+	}
+	left := p.group[len(p.group)-1]
+	p.group[len(p.group)-1] = searchTerm{Near: []searchTerm{left, term}, Distance: p.nearDistance}
+}
+
+// Keeps the first error found.
+func (p *searchQueryParser) fail(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// Returns true if any term of the query uses NEAR.
+func (q searchQuery) hasNear() bool {
+	for _, group := range q.Groups {
+		for _, term := range group {
+			if len(term.Near) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Formats the query for FTS5 MATCH. Every term is quoted, and terms only contain clean text,
+// so nothing from the search text is interpreted as FTS5 syntax.
+func (q searchQuery) ftsQuery() string {
+	groups := make([]string, 0, len(q.Groups))
+	for _, group := range q.Groups {
+		var included, excluded []string
+		for _, term := range group {
+			if term.Exclude {
+				excluded = append(excluded, term.ftsPhrase())
+			} else {
+				included = append(included, term.ftsPhrase())
+			}
+		}
+
+		expr := strings.Join(included, " AND ")
+		if len(included) > 1 && len(excluded) > 0 {
+			expr = "(" + expr + ")"
+		}
+		for _, phrase := range excluded {
+			expr += " NOT " + phrase
+		}
+		groups = append(groups, expr)
+	}
+
+	if len(groups) == 1 {
+		return groups[0]
+	}
+	return "(" + strings.Join(groups, ") OR (") + ")"
+}
+
+// Formats the term for FTS5. NEAR terms match lines with either side, since the other side can be on the
+// line before or after. Their distance is checked by the near_match SQL function.
+func (t searchTerm) ftsPhrase() string {
+	if len(t.Near) > 0 {
+		return "(" + t.Near[0].ftsPhrase() + " OR " + t.Near[1].ftsPhrase() + ")"
+	}
+	if len(t.Variants) > 0 {
+		return `("` + strings.Join(t.Variants, `" OR "`) + `")`
+	}
+	phrase := `"` + t.Text + `"`
+	if t.Prefix {
+		phrase += " *"
+	}
+	return phrase
+}
+
+// Returns a regex pattern matching the term in clean text.
+func (t searchTerm) pattern(matchWholeWord bool) string {
+	if len(t.Variants) > 0 {
+		patterns := make([]string, len(t.Variants))
+		for i, variant := range t.Variants {
+			patterns[i] = searchTerm{Text: variant}.pattern(matchWholeWord)
+		}
+		return "(?:" + strings.Join(patterns, "|") + ")"
+	}
+	if !matchWholeWord {
+		return regexp.QuoteMeta(t.Text)
+	}
+	pattern := wholeWordPattern(t.Text)
+	if t.Prefix {
+		pattern = strings.TrimSuffix(pattern, `\b`)
+	}
+	return pattern
+}
+
+// Returns a regex pattern matching any of the terms that are not excluded, used to count matches in clean text.
+func (q searchQuery) countPattern(matchWholeWord bool) string {
+	var patterns []string
+	seen := make(map[string]bool)
+	for _, group := range q.Groups {
+		for _, term := range group {
+			if term.Exclude {
+				continue
+			}
+			for _, t := range append([]searchTerm{term}, term.Near...) {
+				if t.Text == "" {
+					continue
+				}
+				pattern := t.pattern(matchWholeWord)
+				if !seen[pattern] {
+					seen[pattern] = true
+					patterns = append(patterns, pattern)
+				}
+			}
+		}
+	}
+	return strings.Join(patterns, "|")
+}
+
+// Appends a condition that the clean text of the line (aliased tl) has every term of one of the groups
+// as a whole word. Excluded terms are already handled by the FTS5 query, and NEAR terms by near_match,
+// which only matches whole words.
+func (q searchQuery) buildWholeWordQuery(qParams *strings.Builder, sqlArgs *[]any) {
+	qParams.WriteString(" AND (")
+	for i, group := range q.Groups {
+		if i > 0 {
+			qParams.WriteString(" OR ")
+		}
+		qParams.WriteString("(1=1")
+		for _, term := range group {
+			if term.Exclude || len(term.Near) > 0 {
+				continue
+			}
+			qParams.WriteString(" AND regexp(?, tl.clean_text)")
+			*sqlArgs = append(*sqlArgs, term.pattern(true))
+		}
+		qParams.WriteString(")")
+	}
+	qParams.WriteString(")")
+}
+
+// SQL for the clean text of the line before and after the line aliased tl, in the same track.
+const (
+	prevLineCleanTextSQL = `COALESCE((SELECT p.clean_text FROM transcript_lines p
+		WHERE p.transcript_id = tl.transcript_id AND p.lang = tl.lang AND (p.start_ms, p.cue_index) < (tl.start_ms, tl.cue_index)
+		ORDER BY p.start_ms DESC, p.cue_index DESC LIMIT 1), '')`
+	nextLineCleanTextSQL = `COALESCE((SELECT n.clean_text FROM transcript_lines n
+		WHERE n.transcript_id = tl.transcript_id AND n.lang = tl.lang AND (n.start_ms, n.cue_index) > (tl.start_ms, tl.cue_index)
+		ORDER BY n.start_ms, n.cue_index LIMIT 1), '')`
+)
+
+// Appends a condition that the line (aliased tl) matches the NEAR terms of the query, if it has any.
+// nearDistance is the distance used by NEAR without /N, or nil for the default.
+func buildNearQuery(qParams *strings.Builder, sqlArgs *[]any, query searchQuery, searchText string, nearDistance *int) {
+	if !query.hasNear() {
+		return
+	}
+	distance := -1
+	if nearDistance != nil {
+		distance = *nearDistance
+	}
+	fmt.Fprintf(qParams, " AND near_match(?, ?, %s, tl.clean_text, %s)", prevLineCleanTextSQL, nextLineCleanTextSQL)
+	*sqlArgs = append(*sqlArgs, searchText, distance)
+}
+
+// Implements the near_match SQL function. Checks the clean text of a line against the search query,
+// taking the other side of each NEAR from the line itself or the line before or after it.
+// Words are compared exactly, without the stemming done by FTS5. A negative nearDistance uses the default distance.
+func matchNearLines(searchText string, nearDistance int64, prev, line, next string) (bool, error) {
+	query, err := parseSearchQuery(searchText)
+	if err != nil {
+		return false, err
+	}
+	if nearDistance < 0 {
+		nearDistance = defaultNearDistance
+	}
+
+	words := [3][]string{strings.Fields(prev), strings.Fields(line), strings.Fields(next)}
+	for _, group := range query.Groups {
+		if groupMatchesLine(group, words, int(nearDistance)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Checks a group of terms against a line, given the words of the previous line, the line and the next line.
+func groupMatchesLine(group []searchTerm, words [3][]string, nearDistance int) bool {
+	for _, term := range group {
+		switch {
+		case len(term.Near) > 0:
+			distance := nearDistance
+			if term.Distance >= 0 {
+				distance = term.Distance
+			}
+			if !nearMatches(term.Near[0], term.Near[1], words, distance) {
+				return false
+			}
+		case term.Exclude:
+			if len(termHits(term, words[1], 0)) > 0 {
+				return false
+			}
+		default:
+			if len(termHits(term, words[1], 0)) == 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Reports whether a and b are within distance words of each other, with at least one of them in the line.
+// The lines before and after count as if they were joined to the line.
+func nearMatches(a, b searchTerm, words [3][]string, distance int) bool {
+	hits := func(term searchTerm) []textSpan {
+		var spans []textSpan
+		offset := 0
+		for _, w := range words {
+			spans = append(spans, termHits(term, w, offset)...)
+			offset += len(w)
+		}
+		return spans
+	}
+	lineStart, lineEnd := len(words[0]), len(words[0])+len(words[1])
+	inLine := func(s textSpan) bool { return s.start >= lineStart && s.end <= lineEnd }
+
+	bHits := hits(b)
+	for _, x := range hits(a) {
+		for _, y := range bHits {
+			if !inLine(x) && !inLine(y) {
+				continue
+			}
+			gap := 0 // Overlapping hits are next to each other
+			if x.end <= y.start {
+				gap = y.start - x.end
+			} else if y.end <= x.start {
+				gap = x.start - y.end
+			}
+			if gap <= distance {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns the word positions of the term in words, shifted by offset.
+func termHits(term searchTerm, words []string, offset int) []textSpan {
+	termWords := strings.Fields(term.Text)
+	var hits []textSpan
+	for i := 0; i+len(termWords) <= len(words); i++ {
+		match := true
+		for j, tw := range termWords {
+			w := words[i+j]
+			if j == len(termWords)-1 && term.Prefix {
+				match = strings.HasPrefix(w, tw)
+			} else {
+				match = w == tw
+			}
+			if !match {
+				break
+			}
+		}
+		if match {
+			hits = append(hits, textSpan{offset + i, offset + i + len(termWords)})
+		}
+	}
+	return hits
+}
+
+// Limits of searchMode=regex. RE2 runs in linear time, so the size of the compiled pattern bounds the work
+// done per line, and the timeout bounds the number of lines scanned when the trigram index can't narrow them down.
+const (
+	maxSearchRegexLength       = 256
+	maxSearchRegexInstructions = 2000
+)
+
+var regexSearchTimeout = 10 * time.Second
+
+var errSearchTimeout = errors.New("search took too long")
+
+// Compiles the pattern of a regex search. Returns an error if it is not valid RE2 syntax, is too complex,
+// or matches empty text, which would match every line.
+func compileSearchRegex(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) > maxSearchRegexLength {
+		return nil, fmt.Errorf("regex is longer than %d characters", maxSearchRegexLength)
+	}
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	prog, err := syntax.Compile(parsed.Simplify())
+	if err != nil {
+		return nil, err
+	}
+	if len(prog.Inst) > maxSearchRegexInstructions {
+		return nil, errors.New("regex is too complex")
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if re.MatchString("") {
+		return nil, errors.New("regex matches empty text")
+	}
+	return re, nil
+}
+
+// Returns text that every match of the regex contains. Alternatives and optional parts are skipped,
+// so the result may be empty.
+func regexLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCapture, syntax.OpPlus:
+		return regexLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			return regexLiterals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		// Literals next to each other are joined, so longer text can be searched for.
+		var literals []string
+		var run []rune
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpLiteral {
+				run = append(run, sub.Rune...)
+				continue
+			}
+			if len(run) > 0 {
+				literals = append(literals, string(run))
+				run = nil
+			}
+			literals = append(literals, regexLiterals(sub)...)
+		}
+		if len(run) > 0 {
+			literals = append(literals, string(run))
+		}
+		return literals
+	}
+	return nil
+}
+
+// Formats the literals of a regex as a transcript_trigram MATCH query. Literals shorter than 3 characters
+// can't be searched for in a trigram index and are left out. Returns an empty string if none are left.
+func trigramQuery(literals []string) string {
+	var phrases []string
+	for _, literal := range literals {
+		if utf8.RuneCountInString(literal) >= 3 {
+			phrases = append(phrases, `"`+strings.ReplaceAll(literal, `"`, `""`)+`"`)
+		}
+	}
+	return strings.Join(phrases, " AND ")
+}
+
+// Appends the conditions of a regex search to the line aliased tl. The pattern must have been checked by compileSearchRegex.
+// Lines are narrowed down with the trigram index when the pattern has enough literal text.
+func buildRegexQuery(qParams *strings.Builder, sqlArgs *[]any, pattern string) {
+	if parsed, err := syntax.Parse(pattern, syntax.Perl); err == nil {
+		if query := trigramQuery(regexLiterals(parsed)); query != "" {
+			qParams.WriteString(" AND tl.rowid IN (SELECT rowid FROM transcript_trigram WHERE transcript_trigram MATCH ?)")
+			*sqlArgs = append(*sqlArgs, query)
+		}
+	}
+	qParams.WriteString(" AND regexp(?, tl.text)")
+	*sqlArgs = append(*sqlArgs, pattern)
+}
+
+// Returns errSearchTimeout if ctx ran out of time, otherwise err.
+func searchTimeoutError(ctx context.Context, err error) error {
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return errSearchTimeout
+	}
+	return err
+}
+
+// Parses the search text of the query data, and expands its terms in fuzzy mode.
+// Returns an empty searchQuery in regex mode, where the search text is a pattern.
+func (a *App) parseQueryDataSearch(ctx context.Context, queryData QueryData) (searchQuery, error) {
+	switch queryData.SearchMode {
+	case SearchModeRegex:
+		return searchQuery{}, nil
+	case SearchModeFuzzy:
+		query, err := parseFuzzySearchQuery(queryData.SearchText)
+		if err != nil {
+			return searchQuery{}, err
+		}
+		return query, a.expandFuzzyTerms(ctx, &query)
+	}
+	return parseSearchQuery(queryData.SearchText)
+}
+
+// Appends the joins and conditions selecting the lines (aliased tl) that match the search text.
+// In text mode lines are matched by the FTS5 index and the NEAR check, in regex mode by the regexp SQL function.
+// joins can be nil if the query already joins transcript_search as ts.
+func buildSearchQuery(joins, qParams *strings.Builder, sqlArgs *[]any, queryData QueryData, search searchQuery) {
+	if queryData.SearchMode == SearchModeRegex {
+		buildRegexQuery(qParams, sqlArgs, queryData.SearchText)
+		return
+	}
+	if joins != nil {
+		joins.WriteString(" JOIN transcript_search ts ON tl.rowid = ts.rowid")
+	}
+	qParams.WriteString(" AND ts.clean_text MATCH ?")
+	*sqlArgs = append(*sqlArgs, search.ftsQuery())
+	buildNearQuery(qParams, sqlArgs, search, queryData.SearchText, queryData.NearDistance)
+}
+
+// Returns the regex used to count matches of the search text in a line, and the column of the line (aliased tl)
+// to count them in: the clean text in text and fuzzy mode, and the original text in regex mode.
+// search is the query returned by parseQueryDataSearch.
+func (a *App) getCountRegex(queryData QueryData, search searchQuery) (*regexp.Regexp, string, error) {
+	switch queryData.SearchMode {
+	case SearchModeRegex:
+		re, err := compileSearchRegex(queryData.SearchText)
+		return re, "tl.text", err
+	case SearchModeFuzzy:
+		// Variants depend on the transcripts, so the regex isn't cached.
+		re, err := regexp.Compile(search.countPattern(queryData.MatchWholeWord))
+		return re, "tl.clean_text", err
+	}
+	re, err := a.getRegex(queryData.SearchText, queryData.MatchWholeWord)
+	return re, "tl.clean_text", err
+}
+
+// Limits of searchMode=fuzzy and of search suggestions.
+const (
+	maxFuzzyVariants     = 10 // Variants of a fuzzy word, the word itself included
+	maxFuzzyPhrases      = 25 // Variants of a fuzzy phrase, which are combinations of the variants of its words
+	maxSearchSuggestions = 3
+)
+
+var errFuzzyNear = errors.New("NEAR can't be used in fuzzy mode")
+
+// Parses the search text of a fuzzy search. NEAR is checked word by word by near_match, which doesn't know the variants.
+func parseFuzzySearchQuery(searchText string) (searchQuery, error) {
+	query, err := parseSearchQuery(searchText)
+	if err == nil && query.hasNear() {
+		return searchQuery{}, errFuzzyNear
+	}
+	return query, err
+}
+
+// Returns the number of edits allowed between a word and its fuzzy variants. Short words are left as they are,
+// since almost every short word is an edit or two away from many others.
+func fuzzyEditDistance(word string) int {
+	switch n := utf8.RuneCountInString(word); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// Implements the edit_distance SQL function. Returns the Levenshtein distance between a and b, in characters.
+func editDistance(a, b string) int64 {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int64, len(rb)+1)
+	curr := make([]int64, len(rb)+1)
+	for j := range prev {
+		prev[j] = int64(j)
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = int64(i)
+		for j := 1; j <= len(rb); j++ {
+			cost := int64(1)
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// Returns up to limit words of the transcript_vocab table that are at least minDistance and at most
+// fuzzyEditDistance(word) edits from word, the closest and then the most common first.
+// Like the prefix length of other fuzzy searches, the words start with the same character as word,
+// so that only that range of the vocabulary is read instead of all of it.
+// The vocabulary covers every transcript, so callers must only return words that were found under the filters of the search.
+func (a *App) closeWords(ctx context.Context, word string, minDistance, limit int) ([]string, error) {
+	maxDistance := fuzzyEditDistance(word)
+	if maxDistance < minDistance {
+		return nil, nil
+	}
+	length := utf8.RuneCountInString(word)
+	first, _ := utf8.DecodeRuneInString(word)
+
+	// The candidates are materialized so edit_distance runs once per term, not once per use of the distance.
+	rows, err := a.db.QueryContext(ctx, `
+		WITH candidates AS MATERIALIZED (
+			SELECT term, doc, edit_distance(term, ?) AS distance FROM transcript_vocab
+			WHERE term >= ? AND term < ? AND length(term) BETWEEN ? AND ?
+		)
+		SELECT term FROM candidates
+		WHERE distance BETWEEN ? AND ?
+		ORDER BY distance, doc DESC, term
+		LIMIT ?`,
+		word, string(first), string(first+1), length-maxDistance, length+maxDistance, minDistance, maxDistance, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vocabulary: %w", err)
+	}
+	defer rows.Close()
+
+	var words []string
+	for rows.Next() {
+		var term string
+		if err := rows.Scan(&term); err != nil {
+			return nil, fmt.Errorf("failed to scan vocabulary row: %w", err)
+		}
+		words = append(words, term)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating vocabulary rows: %w", err)
+	}
+	return words, nil
+}
+
+// Returns the words of the vocabulary close to word, the word itself first even if it's not in the vocabulary.
+func (a *App) fuzzyVariants(ctx context.Context, word string) ([]string, error) {
+	words, err := a.closeWords(ctx, word, 0, maxFuzzyVariants)
+	if err != nil {
+		return nil, err
+	}
+	words = slices.DeleteFunc(words, func(w string) bool { return w == word })
+	if len(words) == maxFuzzyVariants {
+		words = words[:maxFuzzyVariants-1]
+	}
+	return append([]string{word}, words...), nil
+}
+
+// Sets the variants of every term of the query that is not excluded or a prefix. The variants of a phrase
+// are the combinations of the variants of its words, with fewer variants of the words that have the most
+// until there are at most maxFuzzyPhrases of them.
+func (a *App) expandFuzzyTerms(ctx context.Context, query *searchQuery) error {
+	for _, group := range query.Groups {
+		for i, term := range group {
+			if term.Exclude || term.Prefix || term.Text == "" {
+				continue
+			}
+
+			words := strings.Fields(term.Text)
+			wordVariants := make([][]string, len(words))
+			for j, word := range words {
+				variants, err := a.fuzzyVariants(ctx, word)
+				if err != nil {
+					return err
+				}
+				wordVariants[j] = variants
+			}
+			for phraseCount(wordVariants) > maxFuzzyPhrases {
+				longest := 0
+				for j, variants := range wordVariants {
+					if len(variants) > len(wordVariants[longest]) {
+						longest = j
+					}
+				}
+				wordVariants[longest] = wordVariants[longest][:len(wordVariants[longest])-1]
+			}
+
+			phrases := []string{""}
+			for _, variants := range wordVariants {
+				var next []string
+				for _, phrase := range phrases {
+					for _, variant := range variants {
+						next = append(next, strings.TrimSpace(phrase+" "+variant))
+					}
+				}
+				phrases = next
+			}
+			group[i].Variants = phrases
+		}
+	}
+	return nil
+}
+
+// Returns the number of phrases made by combining one variant of each word.
+func phraseCount(wordVariants [][]string) int {
+	count := 1
+	for _, variants := range wordVariants {
+		count *= len(variants)
+	}
+	return count
+}
+
+// Returns the fuzzy terms of the query with the variants that are found in the lines.
+// Terms are listed once, in query order.
+func (q searchQuery) fuzzyTerms(lines []string) []FuzzyTerm {
+	cleanLines := make([]string, len(lines))
+	for i, line := range lines {
+		cleanLines[i] = " " + normalizeText(line) + " "
+	}
+
+	var terms []FuzzyTerm
+	seen := make(map[string]bool)
+	for _, group := range q.Groups {
+		for _, term := range group {
+			if len(term.Variants) == 0 || seen[term.Text] {
+				continue
+			}
+			seen[term.Text] = true
+
+			matched := make([]string, 0)
+			for _, variant := range term.Variants {
+				if slices.ContainsFunc(cleanLines, func(line string) bool { return strings.Contains(line, " "+variant+" ") }) {
+					matched = append(matched, variant)
+				}
+			}
+			terms = append(terms, FuzzyTerm{Term: term.Text, Variants: term.Variants, Matched: matched})
+		}
+	}
+	return terms
+}
+
+// Drops the variants of the fuzzy terms, other than the term itself, that have no results under the filters of the search.
+// The variants come from the vocabulary of every transcript, which would otherwise list the words of members streams to anyone.
+func (a *App) filterFuzzyVariants(ctx context.Context, queryData QueryData, terms []FuzzyTerm) error {
+	for i, term := range terms {
+		variants := []string{term.Variants[0]}
+		for _, variant := range term.Variants[1:] {
+			if !slices.Contains(term.Matched, variant) {
+				variantData := queryData
+				variantData.SearchText = variant
+				variantData.SearchMode = SearchModeText
+				found, err := a.searchHasResults(ctx, variantData, searchQuery{Groups: [][]searchTerm{{{Text: variant}}}})
+				if err != nil {
+					return err
+				}
+				if !found {
+					continue
+				}
+			}
+			variants = append(variants, variant)
+		}
+		terms[i].Variants = variants
+	}
+	return nil
+}
+
+// Returns the corrections of the words of the query that are not in the vocabulary, closest first.
+// Words of excluded and prefix terms are left as they are.
+func (a *App) searchCorrections(ctx context.Context, query searchQuery) (map[string][]string, error) {
+	corrections := make(map[string][]string)
+	for _, group := range query.Groups {
+		for _, term := range group {
+			for _, t := range append([]searchTerm{term}, term.Near...) {
+				if t.Exclude || t.Prefix {
+					continue
+				}
+				for _, word := range strings.Fields(t.Text) {
+					if _, ok := corrections[word]; ok {
+						continue
+					}
+					words, err := a.closeWords(ctx, word, 0, maxSearchSuggestions+1)
+					if err != nil {
+						return nil, err
+					}
+					if len(words) > 0 && words[0] == word {
+						words = nil // Spelled like a word in the transcripts
+					}
+					corrections[word] = words
+				}
+			}
+		}
+	}
+	return corrections, nil
+}
+
+// Returns search texts with the misspelled words of the search corrected, that have results under the same filters.
+// The nth suggestion uses the nth closest correction of each word, or its last one if it has fewer.
+func (a *App) searchSuggestions(ctx context.Context, queryData QueryData, query searchQuery) ([]string, error) {
+	corrections, err := a.searchCorrections(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := make([]string, 0)
+	for n := range maxSearchSuggestions {
+		replacements := make(map[string]string)
+		for word, words := range corrections {
+			if len(words) > 0 {
+				replacements[word] = words[min(n, len(words)-1)]
+			}
+		}
+		suggestion := replaceSearchWords(queryData.SearchText, replacements)
+		if suggestion == queryData.SearchText || slices.Contains(suggestions, suggestion) {
+			continue
+		}
+
+		suggestionData := queryData
+		suggestionData.SearchText = suggestion
+		suggestionData.SearchMode = SearchModeText
+		search, err := parseSearchQuery(suggestion)
+		if err != nil {
+			continue
+		}
+		found, err := a.searchHasResults(ctx, suggestionData, search)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	return suggestions, nil
+}
+
+// Replaces the words of the search text that are keys of replacements, keeping the quotes, operators and
+// punctuation around them. Only parts of the text that are a single word are replaced.
+func replaceSearchWords(searchText string, replacements map[string]string) string {
+	fields := strings.Fields(searchText)
+	for i, field := range fields {
+		if field == searchOperatorAnd || field == searchOperatorOr || field == searchOperatorNear ||
+			strings.HasPrefix(field, searchOperatorNear+"/") || strings.HasPrefix(field, "-") {
+			continue
+		}
+		spans := textSpans(field)
+		if len(spans) != 1 {
+			continue
+		}
+		word := strings.ToLower(field[spans[0].start:spans[0].end])
+		if replacement, ok := replacements[word]; ok {
+			fields[i] = field[:spans[0].start] + replacement + field[spans[0].end:]
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// Page sizes of /transcripts.
+const (
+	defaultSearchLimit  = 50
+	maxSearchLimit      = 200
+	defaultContextLimit = 20
+	maxContextLimit     = 100
+	maxContextLines     = 10 // Lines before or after each context
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// searchCursor is the position of the last transcript of a page of search results. The next page starts after it.
+type searchCursor struct {
+	Sort  string  `json:"s"`
+	Key   string  `json:"k,omitempty"` // Date or title of the transcript, when sorting by them
+	Value float64 `json:"v,omitempty"` // Score or number of matching lines of the transcript, when sorting by them
+	ID    string  `json:"i"`
+}
+
+// searchOrder is the order of the pages of /transcripts. Transcripts with the same sort key are ordered by ID.
+type searchOrder struct {
+	Sort      string
+	Key       string // SQL of the sort key in the metadata query
+	Desc      bool
+	Aggregate bool // The key is computed over the matching lines of each transcript, so it's compared after grouping
+}
+
+// Returns the order of the search. relevance and matches need search text, and sort by date without it.
+func searchSortOrder(queryData QueryData) searchOrder {
+	sort := queryData.Sort
+	if queryData.SearchText == "" && (sort == SearchSortRelevance || sort == SearchSortMatches) {
+		sort = SearchSortNewest
+	}
+	switch sort {
+	case SearchSortRelevance:
+		return searchOrder{Sort: sort, Key: "score", Desc: true, Aggregate: true}
+	case SearchSortMatches:
+		return searchOrder{Sort: sort, Key: "matches", Desc: true, Aggregate: true}
+	case SearchSortOldest:
+		return searchOrder{Sort: sort, Key: "t.date"}
+	case SearchSortTitle:
+		return searchOrder{Sort: sort, Key: "t.title COLLATE NOCASE"}
+	}
+	return searchOrder{Sort: SearchSortNewest, Key: "t.date", Desc: true}
+}
+
+// Returns the SQL ordering the metadata query.
+func (o searchOrder) orderBy() string {
+	if o.Desc {
+		return " ORDER BY " + o.Key + " DESC, t.id"
+	}
+	return " ORDER BY " + o.Key + ", t.id"
+}
+
+// Returns the condition selecting the transcripts after the cursor, and its args.
+func (o searchOrder) after(cursor *searchCursor) (string, []any) {
+	op := ">"
+	if o.Desc {
+		op = "<"
+	}
+	var key any = cursor.Key
+	if o.Aggregate {
+		key = cursor.Value
+	}
+	return fmt.Sprintf(" (%s %s ? OR (%s = ? AND t.id > ?))", o.Key, op, o.Key), []any{key, key, cursor.ID}
+}
+
+// Returns the cursor of the page after the one ending with res.
+func (o searchOrder) encodeCursor(res *TranscriptSearch) string {
+	cursor := searchCursor{Sort: o.Sort, ID: res.ID}
+	switch o.Sort {
+	case SearchSortRelevance:
+		cursor.Value = res.Score
+	case SearchSortMatches:
+		cursor.Value = float64(res.TotalContexts)
+	case SearchSortTitle:
+		cursor.Key = res.Title
+	default:
+		cursor.Key = res.Date
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decodes a cursor returned by encodeCursor. Returns nil for the first page, when s is empty.
+// A cursor can only be used with the order of the page it came from.
+func (o searchOrder) decodeCursor(s string) (*searchCursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var cursor searchCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" || cursor.Sort != o.Sort {
+		return nil, errInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// SQL of the stream metadata (aliased t) that each facet groups the search results by.
+var searchFacetColumns = map[string]string{
+	SearchFacetStreamer:   "t.streamer",
+	SearchFacetStreamType: "t.stream_type",
+	SearchFacetYear:       "substr(t.date, 1, 4)",
+	SearchFacetMonth:      "substr(t.date, 1, 7)",
+}
+
+// Counts the transcripts and matching lines for each value of the facets of the query data.
+// from is the FROM and WHERE clauses of the search, with its args, so the counts follow the same filters and membership restriction.
+// Values are ordered by their number of matches, or of transcripts without search text.
+func (a *App) countFacets(ctx context.Context, queryData QueryData, from string, sqlArgs []any) (map[string][]FacetCount, error) {
+	counts := "COUNT(*), 0"
+	if queryData.SearchText != "" {
+		counts = "COUNT(DISTINCT t.id), COUNT(*)"
+	}
+
+	facets := make(map[string][]FacetCount, len(queryData.Facets))
+	for _, facet := range queryData.Facets {
+		query := "SELECT " + searchFacetColumns[facet] + " AS value, " + counts + from + " GROUP BY value ORDER BY COUNT(*) DESC, value"
+		rows, err := a.db.QueryContext(ctx, query, sqlArgs...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count %s facet: %w", facet, err)
+		}
+
+		values := []FacetCount{}
+		for rows.Next() {
+			var count FacetCount
+			if err := rows.Scan(&count.Value, &count.Transcripts, &count.Matches); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan %s facet: %w", facet, err)
+			}
+			values = append(values, count)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("error during rows iteration: %w", err)
+		}
+		facets[facet] = values
+	}
+	return facets, nil
+}
@@ -0,0 +1,266 @@
+package internal
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseSearchQuery_FTS(t *testing.T) {
+	tests := []struct {
+		searchText string
+		want       string
+	}{
+		{"Hello World", `"hello world"`},
+		{"and or not", `"and or not"`},
+		{"cat AND dog", `"cat" AND "dog"`},
+		{`"good morning" "good night"`, `"good morning" AND "good night"`},
+		{"cat OR dog", `("cat") OR ("dog")`},
+		{"cat -dog", `"cat" NOT "dog"`},
+		{`cat fish -"hot dog" -bird`, `"cat fish" NOT "hot dog" NOT "bird"`},
+		{"cat AND fish -dog", `("cat" AND "fish") NOT "dog"`},
+		{"sub* OR member", `("sub" *) OR ("member")`},
+		{"good mor* night", `"good mor" * AND "night"`},
+		{`"good mor"*`, `"good mor" *`},
+		{"rock-n-roll", `"rock n roll"`},
+		{`NEAR("a" "b") ^c`, `"near" AND "a" AND "b" AND "^c"`}, // Symbols are kept, but only ever inside quotes
+		{"猫 OR 犬", `("猫") OR ("犬")`},
+		{`say "unterminated`, `"say" AND "unterminated"`},
+		{"minecraft NEAR again", `("minecraft" OR "again")`},
+		{`big game NEAR/3 "play again" -boss`, `("big game" OR "play again") NOT "boss"`},
+		{"near far", `"near far"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.searchText, func(t *testing.T) {
+			query, err := parseSearchQuery(tt.searchText)
+			if err != nil {
+				t.Fatalf("parseSearchQuery(%q) failed: %v", tt.searchText, err)
+			}
+			if got := query.ftsQuery(); got != tt.want {
+				t.Errorf("ftsQuery() for %q = %s, want %s", tt.searchText, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSearchQuery_Errors(t *testing.T) {
+	tests := []struct {
+		searchText string
+		want       error
+	}{
+		{"", errNoSearchTerms},
+		{"!!! ...", errNoSearchTerms},
+		{"AND OR", errNoSearchTerms},
+		{"-dog", errOnlyExcludedTerms},
+		{"cat OR -dog", errOnlyExcludedTerms},
+		{"NEAR cat", errNearMissingTerm},
+		{"cat NEAR", errNearMissingTerm},
+		{"cat NEAR -dog", errNearMissingTerm},
+		{"cat NEAR OR dog", errNearMissingTerm},
+		{"cat NEAR dog NEAR fish", errNearChain},
+		{"hello OR", errDanglingOperator},
+		{"OR hello", errDanglingOperator},
+		{"OR cat OR", errDanglingOperator},
+		{"cat AND", errDanglingOperator},
+		{"cat OR OR dog", errDanglingOperator},
+		{"cat AND OR dog", errDanglingOperator},
+		{"cat NEAR/x dog", errNearDistance},
+		{"cat NEAR/101 dog", errNearDistance},
+	}
+
+	for _, tt := range tests {
+		if _, err := parseSearchQuery(tt.searchText); err != tt.want {
+			t.Errorf("parseSearchQuery(%q) error = %v, want %v", tt.searchText, err, tt.want)
+		}
+	}
+}
+
+func TestGetRegex_SearchQuery(t *testing.T) {
+	app := &App{
+		regexCache:   make(map[string]*regexp.Regexp),
+		regexCacheMu: sync.Mutex{},
+	}
+
+	tests := []struct {
+		searchText     string
+		matchWholeWord bool
+		line           string
+		want           int
+	}{
+		{"cat OR dog", false, "the cat and the dog and the cat", 3},
+		{"cat -dog", false, "a cat not a dog", 1},
+		{"cat*", true, "cats and cat and concat", 2},
+		{"cat", true, "cats and cat and concat", 1},
+		{`"hot dog" AND bun`, true, "a hot dog in a bun", 2},
+	}
+
+	for _, tt := range tests {
+		re, err := app.getRegex(tt.searchText, tt.matchWholeWord)
+		if err != nil {
+			t.Fatalf("getRegex(%q) failed: %v", tt.searchText, err)
+		}
+		if got := len(re.FindAllStringIndex(normalizeText(tt.line), -1)); got != tt.want {
+			t.Errorf("getRegex(%q, %t) matched %d times in %q, want %d", tt.searchText, tt.matchWholeWord, got, tt.line, tt.want)
+		}
+	}
+
+	if _, err := app.getRegex("-dog", false); err == nil {
+		t.Error("Expected an error for a search with only excluded terms")
+	}
+}
+
+func TestMatchNearLines(t *testing.T) {
+	tests := []struct {
+		searchText   string
+		nearDistance int64
+		prev         string
+		line         string
+		next         string
+		want         bool
+	}{
+		{"minecraft NEAR again", -1, "", "we play minecraft again", "", true},
+		{"again NEAR minecraft", -1, "", "we play minecraft again", "", true},
+		{"minecraft NEAR/2 again", -1, "", "minecraft one two three again", "", false},
+		{"minecraft NEAR/3 again", -1, "", "minecraft one two three again", "", true},
+		{"minecraft NEAR again", 2, "", "minecraft one two three again", "", false},
+		{"again NEAR minecraft", 0, "", "minecraft again", "", true},
+		{"minecraft NEAR again", 0, "", "minecraft now again", "", false},
+		{"minecraft NEAR again", -1, "", "we play minecraft", "", false},
+		{"minecraft NEAR again", -1, "", "we play minecraft", "again tomorrow", true},
+		{"minecraft NEAR again", -1, "so minecraft", "it is again", "", true},
+		{"minecraft NEAR/1 again", -1, "so minecraft", "it is again", "", false},
+		{"minecraft NEAR again", -1, "minecraft", "nothing here", "again", false},
+		{"mine* NEAR again", -1, "", "minecrafting again", "", true},
+		{`"play again" NEAR minecraft`, -1, "play", "again minecraft", "", false},
+		{"minecraft NEAR again -boss", -1, "", "minecraft boss again", "", false},
+		{"cat OR minecraft NEAR again", -1, "", "a cat", "", true},
+		{"猫 NEAR 好き", -1, "猫 が", "好 き", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := matchNearLines(tt.searchText, tt.nearDistance, normalizeText(tt.prev), normalizeText(tt.line), normalizeText(tt.next))
+		if err != nil {
+			t.Fatalf("matchNearLines(%q) failed: %v", tt.searchText, err)
+		}
+		if got != tt.want {
+			t.Errorf("matchNearLines(%q, %d, %q, %q, %q) = %t, want %t", tt.searchText, tt.nearDistance, tt.prev, tt.line, tt.next, got, tt.want)
+		}
+	}
+}
+
+func TestCompileSearchRegex(t *testing.T) {
+	valid := []string{`wa+h+`, `(?i)\bpog(gers)?\b`, `[0-9]{3}`}
+	for _, pattern := range valid {
+		if _, err := compileSearchRegex(pattern); err != nil {
+			t.Errorf("compileSearchRegex(%q) failed: %v", pattern, err)
+		}
+	}
+
+	invalid := []string{
+		`(unclosed`,
+		`a(?=b)`, // Lookahead is not RE2
+		`a*`,     // Matches empty text
+		`x|`,     // Matches empty text
+		strings.Repeat("a", maxSearchRegexLength+1),
+		`(abcdef|ghijkl){300}`,
+	}
+	for _, pattern := range invalid {
+		if _, err := compileSearchRegex(pattern); err == nil {
+			t.Errorf("compileSearchRegex(%q) should fail", pattern)
+		}
+	}
+}
+
+func TestTrigramQuery(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{`wa+h+`, ``},
+		{`(?i)\bpog(gers)?\b`, `"POG"`}, // The trigram index is case-insensitive
+		{`hello (world|there)`, `"hello "`},
+		{`(abc)+def`, `"abc" AND "def"`},
+		{`say "hi"`, `"say ""hi"""`},
+		{`minecraft.*again`, `"minecraft" AND "again"`},
+		{`(?:abc){2}x?yz`, `"abc"`},
+		{`猫が好き`, `"猫が好き"`},
+	}
+
+	for _, tt := range tests {
+		parsed, err := syntax.Parse(tt.pattern, syntax.Perl)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", tt.pattern, err)
+		}
+		if got := trigramQuery(regexLiterals(parsed)); got != tt.want {
+			t.Errorf("trigramQuery for %q = %s, want %s", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int64
+	}{
+		{"pekora", "pekora", 0},
+		{"pekora", "pekira", 1},
+		{"pekora", "pekoras", 1},
+		{"minecaft", "minecraft", 1},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+		{"猫が好き", "猫は好き", 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFuzzyQuery(t *testing.T) {
+	if _, err := parseFuzzySearchQuery("cat NEAR dog"); err != errFuzzyNear {
+		t.Errorf("Expected errFuzzyNear, got %v", err)
+	}
+
+	query := searchQuery{Groups: [][]searchTerm{{
+		{Text: "pekora", Variants: []string{"pekora", "pekira"}},
+		{Text: "cat"},
+	}}}
+	if got, want := query.ftsQuery(), `("pekora" OR "pekira") AND "cat"`; got != want {
+		t.Errorf("ftsQuery() = %s, want %s", got, want)
+	}
+	re := regexp.MustCompile(query.countPattern(true))
+	if got := len(re.FindAllString("pekira and pekora and pekoras and the cat", -1)); got != 3 {
+		t.Errorf("Expected 3 matches of the variants, got %d", got)
+	}
+
+	terms := query.fuzzyTerms([]string{"Pekira!", "hello"})
+	want := []FuzzyTerm{{Term: "pekora", Variants: []string{"pekora", "pekira"}, Matched: []string{"pekira"}}}
+	if len(terms) != 1 || terms[0].Term != want[0].Term || !slices.Equal(terms[0].Matched, want[0].Matched) {
+		t.Errorf("fuzzyTerms() = %+v, want %+v", terms, want)
+	}
+}
+
+func TestReplaceSearchWords(t *testing.T) {
+	replacements := map[string]string{"minecaft": "minecraft", "near": "bear", "pekora": "pekora"}
+	tests := []struct {
+		searchText string
+		want       string
+	}{
+		{"Minecaft", "minecraft"},
+		{`"minecaft" OR sub*`, `"minecraft" OR sub*`},
+		{"play minecaft, again", "play minecraft, again"},
+		{"cat NEAR dog near", "cat NEAR dog bear"},
+		{"-minecaft pekora", "-minecaft pekora"},
+		{"rock-n-minecaft", "rock-n-minecaft"},
+	}
+	for _, tt := range tests {
+		if got := replaceSearchWords(tt.searchText, replacements); got != tt.want {
+			t.Errorf("replaceSearchWords(%q) = %q, want %q", tt.searchText, got, tt.want)
+		}
+	}
+}
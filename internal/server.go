@@ -1,13 +1,19 @@
 package internal
 
 import (
+	"bufio"
+	"bytes"
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/klauspost/compress/zstd"
@@ -17,7 +23,12 @@ import (
 // Initializes all server endpoints and any protected middleware
 func (a *App) InitServerEndpoints(mux *http.ServeMux) {
 	// API Key protected routes
-	mux.HandleFunc("POST /transcript", a.apiKeyMiddleware(a.decompressionMiddleware(a.handlePostTranscript)))
+	mux.HandleFunc("POST /transcript", a.apiKeyMiddleware(a.decompressionMiddleware(maxTranscriptBodySize, a.handlePostTranscript)))
+	mux.HandleFunc("POST /transcripts/bulk", a.apiKeyMiddleware(a.decompressionMiddleware(maxBulkBodySize, a.handleBulkPostTranscripts)))
+	mux.HandleFunc("POST /transcript/validate", a.apiKeyMiddleware(a.decompressionMiddleware(maxTranscriptBodySize, a.handleValidateTranscript)))
+	mux.HandleFunc("DELETE /transcript/{id}", a.apiKeyMiddleware(a.handleDeleteTranscript))
+	mux.HandleFunc("PATCH /transcript/{id}", a.apiKeyMiddleware(a.handlePatchTranscript))
+	mux.HandleFunc("PATCH /transcript/{id}/lines/{lineId}", a.apiKeyMiddleware(a.handlePatchTranscriptLine))
 	mux.HandleFunc("GET /membership/{channelName}", a.apiKeyMiddleware(a.handleGetMembershipKeys))
 	mux.HandleFunc("POST /membership/{channelName}", a.apiKeyMiddleware(a.handleCreateMembershipKey))
 	mux.HandleFunc("DELETE /membership/{channelName}", a.apiKeyMiddleware(a.handleDeleteMembershipKeys))
@@ -33,6 +44,10 @@ func (a *App) InitServerEndpoints(mux *http.ServeMux) {
 	// Membership protected public routes (only the members transcript is protected)
 	mux.HandleFunc("GET /stream/{id}", a.membershipMiddleware(a.handleGetStreamMetadata))
 	mux.HandleFunc("GET /transcript/{id}", a.membershipMiddleware(a.handleGetTranscript))
+	mux.HandleFunc("GET /transcript/{id}/revisions", a.membershipMiddleware(a.handleGetTranscriptRevisions))
+	mux.HandleFunc("GET /transcript/{id}/diff", a.membershipMiddleware(a.handleGetTranscriptDiff))
+	mux.HandleFunc("GET /transcript/{id}/edits", a.membershipMiddleware(a.handleGetTranscriptEdits))
+	mux.HandleFunc("GET /transcript/{id}/search", a.membershipMiddleware(a.handleSearchTranscript))
 	mux.HandleFunc("GET /transcripts", a.membershipMiddleware(a.handleSearchTranscripts))
 	mux.HandleFunc("GET /graph/{id}", a.membershipMiddleware(a.handleGetGraphByID))
 	mux.HandleFunc("GET /graph", a.membershipMiddleware(a.handleGetGraphAll))
@@ -86,8 +101,9 @@ func (a *App) apiKeyMiddleware(next http.HandlerFunc) http.HandlerFunc {
 }
 
 // Check for gzip/zstd content encoding and wrap body if present
-func (a *App) decompressionMiddleware(next http.HandlerFunc) http.HandlerFunc {
+func (a *App) decompressionMiddleware(maxBytes int64, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
 		encoding := r.Header.Get("Content-Encoding")
 		switch encoding {
 		case "gzip":
@@ -98,7 +114,7 @@ func (a *App) decompressionMiddleware(next http.HandlerFunc) http.HandlerFunc {
 				return
 			}
 			defer reader.Close()
-			r.Body = reader
+			r.Body = http.MaxBytesReader(w, reader, maxBytes)
 		case "zstd":
 			reader, err := zstd.NewReader(r.Body)
 			if err != nil {
@@ -107,7 +123,7 @@ func (a *App) decompressionMiddleware(next http.HandlerFunc) http.HandlerFunc {
 				return
 			}
 			defer reader.Close()
-			r.Body = io.NopCloser(reader)
+			r.Body = http.MaxBytesReader(w, io.NopCloser(reader), maxBytes)
 		}
 		next(w, r)
 	}
@@ -121,7 +137,7 @@ func CorsMiddleware(next http.Handler) http.Handler {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 
 		// Set the allowed methods
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
 
 		// Set the allowed headers
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-API-Key, X-Membership-Key, Authorization")
@@ -140,46 +156,352 @@ func CorsMiddleware(next http.Handler) http.Handler {
 }
 
 // Adds the transcript to the database. Handles duplicate transcripts. Protected by API key.
+// With ?strict=true, the upload is rejected with a list of invalid blocks instead of skipping them.
+// Responds 201 when the lines were written, or 200 when the content was already stored (status "updated" if
+// only the metadata changed, "unchanged" otherwise).
 func (a *App) handlePostTranscript(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 	ctx := r.Context()
+	input, ok := decodeTranscriptInput(w, r)
+	if !ok {
+		return
+	}
+
+	if r.URL.Query().Get("strict") == "true" {
+		if validation := validateTranscript(&input); !validation.Valid {
+			Http400Errors.Inc()
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			writeJSON(w, validation)
+			return
+		}
+	}
+
+	// Insert into Database
+	status, err := a.insertTranscript(ctx, &input)
+	if err != nil {
+		slog.Error("failed to insert transcript", "id", input.ID, "err", err)
+		Http500Errors.Inc()
+		writeError(w, http.StatusInternalServerError, "Failed to save transcript")
+		return
+	}
+
+	RequestsProcessingDuration.Observe(time.Since(startTime).Seconds())
+	PostTranscriptProcessingDuration.Observe(time.Since(startTime).Seconds())
+	TotalRequests.Inc()
+	PostTranscriptRequests.Inc()
+	if status != TranscriptStatusCreated {
+		writeJSON(w, map[string]string{"status": status, "id": input.ID})
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, map[string]string{"status": "ok", "id": input.ID})
+}
+
+// Number of transcripts saved per transaction by POST /transcripts/bulk.
+const bulkBatchSize = 50
+
+// Time allowed to read, save and respond to each batch of POST /transcripts/bulk.
+const bulkBatchTimeout = time.Minute
+
+// Largest body of POST /transcript and POST /transcript/validate, before and after decompression.
+const maxTranscriptBodySize = 64 << 20
+
+// Largest body of POST /transcripts/bulk, before and after decompression.
+const maxBulkBodySize = 4 << 30
+
+// Adds every transcript in an NDJSON stream of TranscriptInput objects. Protected by API key.
+// Transcripts are saved in batched transactions. A BulkResult line is streamed back for every input line
+// once its batch is saved, followed by a final BulkSummary line. Supports ?strict=true like POST /transcript.
+func (a *App) handleBulkPostTranscripts(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	ctx := r.Context()
+	strict := r.URL.Query().Get("strict") == "true"
+
+	// A full archive upload takes far longer than the server-wide read/write timeouts allow,
+	// so the deadlines are extended for every batch instead.
+	// Full duplex lets results stream back while the rest of the body is still being read.
+	rc := http.NewResponseController(w)
+	extendDeadlines := func() {
+		_ = rc.SetReadDeadline(time.Now().Add(bulkBatchTimeout))
+		_ = rc.SetWriteDeadline(time.Now().Add(bulkBatchTimeout))
+	}
+	extendDeadlines()
+	_ = rc.EnableFullDuplex()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+
+	var summary BulkSummary
+	var results []BulkResult     // Results waiting to be written, in input order
+	var batch []*TranscriptInput // Transcripts waiting to be saved
+	var batchResultIndexes []int // Index into results for each transcript in batch
+
+	// Saves the pending batch and writes out all pending results.
+	flush := func() {
+		if len(batch) > 0 {
+			statuses, itemErrs, batchErr := a.insertTranscriptBatch(ctx, batch)
+			for i, resultIndex := range batchResultIndexes {
+				res := &results[resultIndex]
+				err := batchErr
+				if err == nil {
+					err = itemErrs[i]
+				}
+				if err != nil {
+					slog.Error("failed to insert bulk transcript", "id", res.ID, "line", res.Line, "err", err)
+					res.Status = TranscriptStatusError
+					res.Error = "Failed to save transcript"
+					continue
+				}
+				res.Status = statuses[i]
+			}
+		}
+
+		for _, res := range results {
+			switch res.Status {
+			case TranscriptStatusError:
+				summary.Failed++
+			case TranscriptStatusUpdated:
+				summary.Updated++
+			case TranscriptStatusUnchanged:
+				summary.Unchanged++
+			default:
+				summary.Created++
+			}
+			if err := encoder.Encode(res); err != nil {
+				slog.Error("failed to write bulk result", "err", err)
+			}
+		}
+		_ = rc.Flush()
+		extendDeadlines()
+
+		results = results[:0]
+		batch = batch[:0]
+		batchResultIndexes = batchResultIndexes[:0]
+	}
+
+	reader := bufio.NewReader(r.Body)
+	lineNumber := 0
+	for {
+		raw, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			slog.Error("failed to read bulk transcripts body", "line", lineNumber+1, "err", readErr)
+			summary.Error = "Failed to read request body"
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(readErr, &maxBytesErr) {
+				summary.Error = "Request body too large"
+			}
+			break
+		}
+		lineNumber++
+
+		if raw = bytes.TrimSpace(raw); len(raw) > 0 {
+			res, input := parseBulkLine(raw, lineNumber, strict)
+			results = append(results, res)
+			if input != nil {
+				batch = append(batch, input)
+				batchResultIndexes = append(batchResultIndexes, len(results)-1)
+				PostBulkTranscriptsItems.Inc()
+			}
+			if len(results) >= bulkBatchSize {
+				flush()
+			}
+		}
+
+		if readErr != nil || ctx.Err() != nil {
+			break // End of body, or the client went away
+		}
+	}
+	flush()
+
+	summary.Done = summary.Error == ""
+	if err := encoder.Encode(summary); err != nil {
+		slog.Error("failed to write bulk summary", "err", err)
+	}
+
+	RequestsProcessingDuration.Observe(time.Since(startTime).Seconds())
+	PostBulkTranscriptsProcessingDuration.Observe(time.Since(startTime).Seconds())
+	TotalRequests.Inc()
+	PostBulkTranscriptsRequests.Inc()
+}
+
+// Decodes and validates a single NDJSON line for POST /transcripts/bulk.
+// Returns the result for the line, and the transcript to save, or nil if the line was rejected.
+func parseBulkLine(raw []byte, lineNumber int, strict bool) (BulkResult, *TranscriptInput) {
+	res := BulkResult{Line: lineNumber, Status: TranscriptStatusCreated}
+
 	var input TranscriptInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		slog.Error("failed to decode post transcript body", "err", err)
+	if err := json.Unmarshal(raw, &input); err != nil {
+		res.Status = TranscriptStatusError
+		res.Error = "Invalid JSON"
+		return res, nil
+	}
+	res.ID = input.ID
+
+	if msg := validateTranscriptInput(&input); msg != "" {
+		res.Status = TranscriptStatusError
+		res.Error = msg
+		return res, nil
+	}
+
+	if strict {
+		if validation := validateTranscript(&input); !validation.Valid {
+			res.Status = TranscriptStatusError
+			res.Error = fmt.Sprintf("%d invalid blocks", len(validation.Rejected))
+			return res, nil
+		}
+	}
+
+	return res, &input
+}
+
+// Parses the transcript the same way POST /transcript would, without saving it. Protected by API key.
+func (a *App) handleValidateTranscript(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	input, ok := decodeTranscriptInput(w, r)
+	if !ok {
+		return
+	}
+
+	validation := validateTranscript(&input)
+
+	RequestsProcessingDuration.Observe(time.Since(startTime).Seconds())
+	ValidateTranscriptProcessingDuration.Observe(time.Since(startTime).Seconds())
+	TotalRequests.Inc()
+	ValidateTranscriptRequests.Inc()
+	writeJSON(w, validation)
+}
+
+// Deletes a transcript and all of its lines. Protected by API key.
+func (a *App) handleDeleteTranscript(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	ctx := r.Context()
+	id := r.PathValue("id")
+	if id == "" {
+		Http400Errors.Inc()
+		writeError(w, http.StatusBadRequest, "Transcript ID is required")
+		return
+	}
+
+	noRows, err := a.deleteTranscript(ctx, id)
+	if err != nil {
+		if noRows {
+			Http400Errors.Inc()
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		slog.Error("failed to delete transcript", "id", id, "err", err)
+		Http500Errors.Inc()
+		writeError(w, http.StatusInternalServerError, "Failed to delete transcript")
+		return
+	}
+
+	RequestsProcessingDuration.Observe(time.Since(startTime).Seconds())
+	DeleteTranscriptProcessingDuration.Observe(time.Since(startTime).Seconds())
+	TotalRequests.Inc()
+	DeleteTranscriptRequests.Inc()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Updates the streamer, date, title, and/or stream type of a transcript. Returns the updated metadata. Protected by API key.
+func (a *App) handlePatchTranscript(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	ctx := r.Context()
+	id := r.PathValue("id")
+	if id == "" {
+		Http400Errors.Inc()
+		writeError(w, http.StatusBadRequest, "Transcript ID is required")
+		return
+	}
+
+	var patch TranscriptPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		slog.Error("failed to decode patch transcript body", "err", err)
 		Http400Errors.Inc()
 		writeError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
+	if msg := validateTranscriptPatch(&patch); msg != "" {
+		Http400Errors.Inc()
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
 
-	if input.ID == "" || input.Streamer == "" || input.Date == "" {
+	metadata, noRows, err := a.updateTranscriptMetadata(ctx, id, &patch)
+	if err != nil {
+		if noRows {
+			Http400Errors.Inc()
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		slog.Error("failed to update transcript metadata", "id", id, "err", err)
+		Http500Errors.Inc()
+		writeError(w, http.StatusInternalServerError, "Failed to update transcript")
+		return
+	}
+
+	RequestsProcessingDuration.Observe(time.Since(startTime).Seconds())
+	PatchTranscriptProcessingDuration.Observe(time.Since(startTime).Seconds())
+	TotalRequests.Inc()
+	PatchTranscriptRequests.Inc()
+	writeJSON(w, metadata)
+}
+
+// Corrects the text of a single transcript line and logs who made the change. Returns the updated line. Protected by API key.
+func (a *App) handlePatchTranscriptLine(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	ctx := r.Context()
+	id := r.PathValue("id")
+	lineID := r.PathValue("lineId")
+	if id == "" || lineID == "" {
 		Http400Errors.Inc()
-		writeError(w, http.StatusBadRequest, "Missing required fields: id, streamer, date")
+		writeError(w, http.StatusBadRequest, "Transcript ID and line ID are required")
 		return
 	}
 
-	if _, err := time.Parse("2006-01-02", input.Date); err != nil {
+	var input LineCorrectionInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		slog.Error("failed to decode patch transcript line body", "err", err)
+		Http400Errors.Inc()
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	input.Text = strings.Join(strings.Fields(input.Text), " ") // Lines are a single line of text, as they are parsed from uploads
+	input.Editor = strings.TrimSpace(input.Editor)
+	if input.Text == "" || input.Editor == "" {
 		Http400Errors.Inc()
-		http.Error(w, "Invalid date format. Expected YYYY-MM-DD", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "Missing required fields: text, editor")
 		return
 	}
 
-	// Insert into Database
-	if err := a.insertTranscript(ctx, &input); err != nil {
-		slog.Error("failed to insert transcript", "id", input.ID, "err", err)
+	line, noRows, err := a.updateTranscriptLine(ctx, id, strings.ToLower(r.URL.Query().Get("lang")), lineID, input.Text, input.Editor)
+	if err != nil {
+		if noRows {
+			Http400Errors.Inc()
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		slog.Error("failed to update transcript line", "id", id, "lineId", lineID, "err", err)
 		Http500Errors.Inc()
-		writeError(w, http.StatusInternalServerError, "Failed to save transcript")
+		writeError(w, http.StatusInternalServerError, "Failed to update transcript line")
 		return
 	}
 
+	slog.Info("corrected transcript line", "id", id, "lineId", lineID, "editor", input.Editor)
 	RequestsProcessingDuration.Observe(time.Since(startTime).Seconds())
-	PostTranscriptProcessingDuration.Observe(time.Since(startTime).Seconds())
+	PatchTranscriptLineProcessingDuration.Observe(time.Since(startTime).Seconds())
 	TotalRequests.Inc()
-	PostTranscriptRequests.Inc()
-	w.WriteHeader(http.StatusCreated)
-	writeJSON(w, map[string]string{"status": "ok", "id": input.ID})
+	PatchTranscriptLineRequests.Inc()
+	writeJSON(w, line)
 }
 
 // Returns a single transcript in json format. Membership is protected.
+// With ?rev=N, returns that revision of the transcript instead of the current one.
+// With ?from=hh:mm:ss&to=hh:mm:ss or ?around=hh:mm:ss&lines=N, returns only a window of its lines.
 func (a *App) handleGetTranscript(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 	ctx := r.Context()
@@ -190,7 +512,27 @@ func (a *App) handleGetTranscript(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	transcript, noRows, err := a.retrieveTranscript(ctx, id)
+	revision, ok := parseRevision(r.URL.Query().Get("rev"))
+	if !ok {
+		Http400Errors.Inc()
+		writeError(w, http.StatusBadRequest, "Invalid rev. Expected a positive integer")
+		return
+	}
+	window, msg := parseTranscriptWindow(r)
+	if msg != "" {
+		Http400Errors.Inc()
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	var transcript TranscriptOutput
+	var noRows bool
+	var err error
+	if revision > 0 {
+		transcript, noRows, err = a.retrieveTranscriptRevision(ctx, id, revision)
+	} else {
+		transcript, noRows, err = a.retrieveTranscriptWindow(ctx, id, strings.ToLower(r.URL.Query().Get("lang")), window)
+	}
 	if err != nil {
 		if noRows {
 			Http400Errors.Inc()
@@ -204,6 +546,11 @@ func (a *App) handleGetTranscript(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if revision > 0 {
+		// Revisions are parsed from their stored content, so their window is taken afterwards.
+		transcript.TranscriptLines = window.slice(transcript.TranscriptLines)
+	}
+
 	RequestsProcessingDuration.Observe(time.Since(startTime).Seconds())
 	GetTranscriptProcessingDuration.Observe(time.Since(startTime).Seconds())
 	TotalRequests.Inc()
@@ -211,13 +558,140 @@ func (a *App) handleGetTranscript(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, transcript)
 }
 
+// Returns the list of stored revisions of a transcript. Membership is protected.
+func (a *App) handleGetTranscriptRevisions(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	ctx := r.Context()
+	id := r.PathValue("id")
+	if id == "" {
+		Http400Errors.Inc()
+		writeError(w, http.StatusBadRequest, "Transcript ID is required")
+		return
+	}
+
+	revisions, noRows, err := a.retrieveTranscriptRevisions(ctx, id)
+	if err != nil {
+		if noRows {
+			Http400Errors.Inc()
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		slog.Error("failed to retrieve transcript revisions", "id", id, "err", err)
+		Http500Errors.Inc()
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve transcript revisions")
+		return
+	}
+
+	RequestsProcessingDuration.Observe(time.Since(startTime).Seconds())
+	GetTranscriptRevisionsProcessingDuration.Observe(time.Since(startTime).Seconds())
+	TotalRequests.Inc()
+	GetTranscriptRevisionsRequests.Inc()
+	writeJSON(w, revisions)
+}
+
+// Returns the log of corrections made to the lines of a transcript track. Membership is protected.
+// ?lang= selects the track, ?lang=und the track without a language, and the default track otherwise.
+func (a *App) handleGetTranscriptEdits(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	ctx := r.Context()
+	id := r.PathValue("id")
+	if id == "" {
+		Http400Errors.Inc()
+		writeError(w, http.StatusBadRequest, "Transcript ID is required")
+		return
+	}
+
+	edits, noRows, err := a.retrieveTranscriptEdits(ctx, id, strings.ToLower(r.URL.Query().Get("lang")))
+	if err != nil {
+		if noRows {
+			Http400Errors.Inc()
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		slog.Error("failed to retrieve transcript edits", "id", id, "err", err)
+		Http500Errors.Inc()
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve transcript edits")
+		return
+	}
+
+	RequestsProcessingDuration.Observe(time.Since(startTime).Seconds())
+	GetTranscriptEditsProcessingDuration.Observe(time.Since(startTime).Seconds())
+	TotalRequests.Inc()
+	GetTranscriptEditsRequests.Inc()
+	writeJSON(w, edits)
+}
+
+// Returns the lines added and removed between two revisions of a transcript. Membership is protected.
+// ?from=N is required. ?to=M defaults to the current revision.
+func (a *App) handleGetTranscriptDiff(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	ctx := r.Context()
+	id := r.PathValue("id")
+	if id == "" {
+		Http400Errors.Inc()
+		writeError(w, http.StatusBadRequest, "Transcript ID is required")
+		return
+	}
+
+	q := r.URL.Query()
+	from, fromOk := parseRevision(q.Get("from"))
+	to, toOk := parseRevision(q.Get("to"))
+	if !fromOk || !toOk || from == 0 {
+		Http400Errors.Inc()
+		writeError(w, http.StatusBadRequest, "Invalid revisions. Expected a positive integer for from, and optionally to")
+		return
+	}
+
+	diff, noRows, err := a.diffTranscriptRevisions(ctx, id, from, to)
+	if errors.Is(err, errRevisionTrackMismatch) {
+		Http400Errors.Inc()
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		if noRows {
+			Http400Errors.Inc()
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		slog.Error("failed to diff transcript revisions", "id", id, "from", from, "to", to, "err", err)
+		Http500Errors.Inc()
+		writeError(w, http.StatusInternalServerError, "Failed to diff transcript revisions")
+		return
+	}
+
+	RequestsProcessingDuration.Observe(time.Since(startTime).Seconds())
+	GetTranscriptDiffProcessingDuration.Observe(time.Since(startTime).Seconds())
+	TotalRequests.Inc()
+	GetTranscriptDiffRequests.Inc()
+	writeJSON(w, diff)
+}
+
 // Performs a filtered search across all transcripts. Membership is protected.
 func (a *App) handleSearchTranscripts(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 	ctx := r.Context()
 
 	queryData := parseQueryData(r)
+	msg := validateSearchParams(r, queryData)
+	if msg == "" {
+		msg = validatePageParams(r, queryData)
+	}
+	if msg != "" {
+		Http400Errors.Inc()
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
 	results, err := a.queryTranscripts(ctx, queryData)
+	if errors.Is(err, errSearchTimeout) {
+		Http400Errors.Inc()
+		writeError(w, http.StatusBadRequest, searchTimeoutMessage)
+		return
+	}
 	if err != nil {
 		slog.Error("failed to query transcripts", "params", queryData, "err", err)
 		Http500Errors.Inc()
@@ -249,8 +723,18 @@ func (a *App) handleGetGraphByID(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "Search text is required")
 		return
 	}
+	if msg := validateSearchParams(r, queryData); msg != "" {
+		Http400Errors.Inc()
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
 
 	graphData, err := a.querySingleGraph(ctx, id, queryData)
+	if errors.Is(err, errSearchTimeout) {
+		Http400Errors.Inc()
+		writeError(w, http.StatusBadRequest, searchTimeoutMessage)
+		return
+	}
 	if err != nil {
 		slog.Error("failed to query single graph", "id", id, "params", queryData, "err", err)
 		Http500Errors.Inc()
@@ -265,6 +749,55 @@ func (a *App) handleGetGraphByID(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, graphData)
 }
 
+// Returns the lines of a transcript track that match the search text, in order. Membership is protected.
+func (a *App) handleSearchTranscript(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	ctx := r.Context()
+	id := r.PathValue("id")
+	if id == "" {
+		Http400Errors.Inc()
+		writeError(w, http.StatusBadRequest, "Transcript ID is required")
+		return
+	}
+
+	queryData := parseQueryData(r)
+	if queryData.SearchText == "" {
+		Http400Errors.Inc()
+		writeError(w, http.StatusBadRequest, "Search text is required")
+		return
+	}
+	if msg := validateSearchParams(r, queryData); msg != "" {
+		Http400Errors.Inc()
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	matches, noRows, err := a.searchTranscriptLines(ctx, id, queryData)
+	if errors.Is(err, errSearchTimeout) {
+		Http400Errors.Inc()
+		writeError(w, http.StatusBadRequest, searchTimeoutMessage)
+		return
+	}
+	if err != nil {
+		if noRows {
+			Http400Errors.Inc()
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		slog.Error("failed to search transcript", "id", id, "params", queryData, "err", err)
+		Http500Errors.Inc()
+		writeError(w, http.StatusInternalServerError, "Failed to search transcript")
+		return
+	}
+
+	RequestsProcessingDuration.Observe(time.Since(startTime).Seconds())
+	SearchTranscriptProcessingDuration.Observe(time.Since(startTime).Seconds())
+	TotalRequests.Inc()
+	SearchTranscriptRequests.Inc()
+	writeJSON(w, matches)
+}
+
 // Returns date-frequency data across all filtered transcripts. Membership is protected.
 func (a *App) handleGetGraphAll(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
@@ -276,8 +809,18 @@ func (a *App) handleGetGraphAll(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "Search text is required")
 		return
 	}
+	if msg := validateSearchParams(r, queryData); msg != "" {
+		Http400Errors.Inc()
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
 
 	graphData, err := a.queryAllGraphs(ctx, queryData)
+	if errors.Is(err, errSearchTimeout) {
+		Http400Errors.Inc()
+		writeError(w, http.StatusBadRequest, searchTimeoutMessage)
+		return
+	}
 	if err != nil {
 		slog.Error("failed to query all graphs", "params", queryData, "err", err)
 		Http500Errors.Inc()
@@ -324,12 +867,12 @@ func (a *App) handleGetStreamMetadata(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, streamData)
 }
 
-// Returns a list of all streams. Open
+// Returns a list of all streams, narrowed by the stream filters of /transcripts. Open
 func (a *App) handleGetInfo(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 	ctx := r.Context()
 
-	results, err := a.retrieveAllStreams(ctx)
+	results, err := a.retrieveAllStreams(ctx, parseQueryData(r))
 	if err != nil {
 		slog.Error("failed to retrieve all streams", "err", err)
 		Http500Errors.Inc()
@@ -520,6 +1063,223 @@ func (a *App) handleVerifyMembershipKey(w http.ResponseWriter, r *http.Request)
 
 // --- HTTP Helper Functions ---
 
+// Decodes and validates the TranscriptInput in the request body. On failure, the error response
+// has already been written and false is returned.
+func decodeTranscriptInput(w http.ResponseWriter, r *http.Request) (TranscriptInput, bool) {
+	var input TranscriptInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		slog.Error("failed to decode post transcript body", "err", err)
+		Http400Errors.Inc()
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			return TranscriptInput{}, false
+		}
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return TranscriptInput{}, false
+	}
+
+	if msg := validateTranscriptInput(&input); msg != "" {
+		Http400Errors.Inc()
+		writeError(w, http.StatusBadRequest, msg)
+		return TranscriptInput{}, false
+	}
+
+	return input, true
+}
+
+// Checks the required fields of a TranscriptInput. Returns an error message, or an empty string if valid.
+func validateTranscriptInput(input *TranscriptInput) string {
+	if input.ID == "" || input.Streamer == "" || input.Date == "" {
+		return "Missing required fields: id, streamer, date"
+	}
+
+	if _, err := time.Parse("2006-01-02", input.Date); err != nil {
+		return "Invalid date format. Expected YYYY-MM-DD"
+	}
+
+	if format := transcriptFormat(input); format != TranscriptFormatSRT && format != TranscriptFormatVTT {
+		return "Invalid format. Expected srt or vtt"
+	}
+
+	if lang := transcriptLang(input); lang != "" && !langRegex.MatchString(lang) {
+		return "Invalid lang. Expected a language code such as en or ja"
+	}
+
+	return ""
+}
+
+// Parses a revision number query parameter. An empty value returns 0.
+// ok is false if the value is set but is not a positive integer.
+func parseRevision(value string) (revision int, ok bool) {
+	if value == "" {
+		return 0, true
+	}
+	revision, err := strconv.Atoi(value)
+	if err != nil || revision < 1 {
+		return 0, false
+	}
+	return revision, true
+}
+
+// Lines returned on each side of the line at ?around= in GET /transcript/:id.
+const (
+	defaultAroundLines = 10
+	maxAroundLines     = 500
+)
+
+// Parses the from, to, around and lines query parameters of GET /transcript/:id. Returns an error message, or an empty string if valid.
+func parseTranscriptWindow(r *http.Request) (window TranscriptWindow, msg string) {
+	q := r.URL.Query()
+	parseTime := func(name string) (*int64, bool) {
+		if !q.Has(name) {
+			return nil, true
+		}
+		ms, ok := parseClockTime(q.Get(name))
+		return &ms, ok
+	}
+	var ok bool
+	if window.From, ok = parseTime("from"); !ok {
+		return TranscriptWindow{}, "Invalid from. Expected a time in hh:mm:ss format"
+	}
+	if window.To, ok = parseTime("to"); !ok {
+		return TranscriptWindow{}, "Invalid to. Expected a time in hh:mm:ss format"
+	}
+	if window.Around, ok = parseTime("around"); !ok {
+		return TranscriptWindow{}, "Invalid around. Expected a time in hh:mm:ss format"
+	}
+
+	if window.Around != nil && (window.From != nil || window.To != nil) {
+		return TranscriptWindow{}, "around can't be used with from or to"
+	}
+	if window.From != nil && window.To != nil && *window.From > *window.To {
+		return TranscriptWindow{}, "Invalid to. Expected a time after from"
+	}
+
+	window.Lines = defaultAroundLines
+	if q.Has("lines") {
+		if window.Around == nil {
+			return TranscriptWindow{}, "lines can only be used with around"
+		}
+		lines, err := strconv.Atoi(q.Get("lines"))
+		if err != nil || lines < 0 || lines > maxAroundLines {
+			return TranscriptWindow{}, fmt.Sprintf("Invalid lines. Expected a number of lines from 0 to %d", maxAroundLines)
+		}
+		window.Lines = lines
+	}
+	return window, ""
+}
+
+// Returned when a regex search runs out of time.
+const searchTimeoutMessage = "Search took too long. Use a regex with more literal text, or narrow the search with filters"
+
+// Checks the search text and search options of a request. Returns an error message, or an empty string if valid.
+func validateSearchParams(r *http.Request, queryData QueryData) string {
+	switch queryData.SearchMode {
+	case "", SearchModeText:
+		if queryData.SearchText != "" {
+			if _, err := parseSearchQuery(queryData.SearchText); err != nil {
+				return "Invalid search text: " + err.Error()
+			}
+		}
+	case SearchModeRegex:
+		if queryData.SearchText != "" {
+			if _, err := compileSearchRegex(queryData.SearchText); err != nil {
+				return "Invalid regex: " + err.Error()
+			}
+		}
+	case SearchModeFuzzy:
+		if queryData.SearchText != "" {
+			if _, err := parseFuzzySearchQuery(queryData.SearchText); err != nil {
+				return "Invalid search text: " + err.Error()
+			}
+		}
+	default:
+		return "Invalid searchMode. Expected text, regex or fuzzy"
+	}
+
+	for _, param := range []string{"fromOffset", "toOffset"} {
+		if value := r.URL.Query().Get(param); value != "" {
+			if _, ok := parseClockTime(value); !ok {
+				return fmt.Sprintf("Invalid %s. Expected a time in hh:mm:ss format", param)
+			}
+		}
+	}
+	if queryData.FromOffset != nil && queryData.ToOffset != nil && *queryData.FromOffset > *queryData.ToOffset {
+		return "Invalid toOffset. Expected a time after fromOffset"
+	}
+
+	if near := r.URL.Query().Get("near"); near != "" {
+		if distance, err := strconv.Atoi(near); err != nil || distance < 0 || distance > maxNearDistance {
+			return fmt.Sprintf("Invalid near. Expected a number of words from 0 to %d", maxNearDistance)
+		}
+	}
+
+	return ""
+}
+
+// Checks the sort and pagination parameters of /transcripts. Returns an error message, or an empty string if valid.
+func validatePageParams(r *http.Request, queryData QueryData) string {
+	for _, facet := range queryData.Facets {
+		if _, ok := searchFacetColumns[facet]; !ok {
+			return "Invalid facets. Expected a comma separated list of streamer, streamType, year or month"
+		}
+	}
+
+	switch queryData.Sort {
+	case "", SearchSortNewest, SearchSortOldest, SearchSortRelevance, SearchSortMatches, SearchSortTitle:
+	default:
+		return "Invalid sort. Expected newest, oldest, relevance, matches or title"
+	}
+
+	q := r.URL.Query()
+	if limit := q.Get("limit"); limit != "" {
+		if n, err := strconv.Atoi(limit); err != nil || n < 1 || n > maxSearchLimit {
+			return fmt.Sprintf("Invalid limit. Expected a number of transcripts from 1 to %d", maxSearchLimit)
+		}
+	}
+	if contextLimit := q.Get("contextLimit"); contextLimit != "" {
+		if n, err := strconv.Atoi(contextLimit); err != nil || n < 1 || n > maxContextLimit {
+			return fmt.Sprintf("Invalid contextLimit. Expected a number of contexts from 1 to %d", maxContextLimit)
+		}
+	}
+	if contextOffset := q.Get("contextOffset"); contextOffset != "" {
+		if n, err := strconv.Atoi(contextOffset); err != nil || n < 0 {
+			return "Invalid contextOffset. Expected a number of contexts to skip"
+		}
+	}
+	for _, param := range []string{"before", "after"} {
+		if value := q.Get(param); value != "" {
+			if n, err := strconv.Atoi(value); err != nil || n < 0 || n > maxContextLines {
+				return fmt.Sprintf("Invalid %s. Expected a number of lines from 0 to %d", param, maxContextLines)
+			}
+		}
+	}
+	if _, err := searchSortOrder(queryData).decodeCursor(queryData.Cursor); err != nil {
+		return "Invalid cursor. Expected the nextCursor of a previous page with the same sort"
+	}
+	return ""
+}
+
+// Checks the fields of a TranscriptPatch. Returns an error message, or an empty string if valid.
+func validateTranscriptPatch(patch *TranscriptPatch) string {
+	if patch.Streamer == nil && patch.Date == nil && patch.StreamType == nil && patch.StreamTitle == nil {
+		return "Nothing to update. Expected at least one of: streamer, date, streamType, streamTitle"
+	}
+
+	if patch.Streamer != nil && *patch.Streamer == "" {
+		return "Streamer cannot be empty"
+	}
+
+	if patch.Date != nil {
+		if _, err := time.Parse("2006-01-02", *patch.Date); err != nil {
+			return "Invalid date format. Expected YYYY-MM-DD"
+		}
+	}
+
+	return ""
+}
+
 func writeJSON(w http.ResponseWriter, data any) {
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(data); err != nil {
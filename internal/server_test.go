@@ -4,8 +4,13 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
 	"testing"
 
@@ -146,6 +151,58 @@ func TestServer_HandlePostTranscript_Upsert(t *testing.T) {
 	}
 }
 
+func TestServer_HandlePostTranscript_Unchanged(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	mux := http.NewServeMux()
+	app.InitServerEndpoints(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	client := ts.Client()
+
+	post := func(body string) (int, map[string]string) {
+		req, _ := http.NewRequest("POST", ts.URL+"/transcript", strings.NewReader(body))
+		req.Header.Set("X-API-Key", app.config.APIKey)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("POST failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var out map[string]string
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	input := `{"id":"u1","streamer":"StreamerA","date":"2023-01-01","streamTitle":"Title","srt":"1\n00:00:01,000 --> 00:00:02,000\nSame text"}`
+	renamed := `{"id":"u1","streamer":"StreamerA","date":"2023-01-01","streamTitle":"New Title","srt":"1\n00:00:01,000 --> 00:00:02,000\nSame text"}`
+
+	tests := []struct {
+		name   string
+		body   string
+		code   int
+		status string
+	}{
+		{"First upload", input, http.StatusCreated, "ok"},
+		{"Identical upload", input, http.StatusOK, TranscriptStatusUnchanged},
+		{"Metadata change", renamed, http.StatusOK, TranscriptStatusUpdated},
+		{"Identical after metadata change", renamed, http.StatusOK, TranscriptStatusUnchanged},
+	}
+	for _, test := range tests {
+		code, out := post(test.body)
+		if code != test.code || out["status"] != test.status || out["id"] != "u1" {
+			t.Errorf("%s: got %d %v, want %d status %q", test.name, code, out, test.code, test.status)
+		}
+	}
+
+	tr, _, err := app.retrieveTranscript(context.Background(), "u1")
+	if err != nil {
+		t.Fatalf("Failed to retrieve u1: %v", err)
+	}
+	if tr.StreamTitle != "New Title" || len(tr.TranscriptLines) != 1 {
+		t.Errorf("Unexpected transcript: %+v", tr)
+	}
+}
+
 func TestServer_CreateTranscript_Validation(t *testing.T) {
 	app := setupTestApp(t)
 	defer app.db.Close()
@@ -195,6 +252,11 @@ func TestServer_CreateTranscript_Validation(t *testing.T) {
 			body:           `{"id":"v1", "streamer":123, "date":"2023-01-01"}`,
 			expectedStatus: http.StatusBadRequest,
 		},
+		{
+			name:           "Invalid Lang",
+			body:           `{"id":"v1", "streamer":"S1", "date":"2023-01-01", "lang":"english!"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
 		{
 			name:           "Missing Date",
 			body:           `{"id":"v1", "streamer":"S1"}`,
@@ -215,6 +277,16 @@ func TestServer_CreateTranscript_Validation(t *testing.T) {
 			body:           `{"id":"v1", "streamer":"S1", "date":"invalid-date"}`,
 			expectedStatus: http.StatusBadRequest,
 		},
+		{
+			name:           "Success (vtt format)",
+			body:           `{"id":"v1", "streamer":"S1", "date":"2023-01-01", "format":"vtt", "vtt":"WEBVTT"}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "Invalid Format",
+			body:           `{"id":"v1", "streamer":"S1", "date":"2023-01-01", "format":"ass"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
 	}
 
 	for _, tt := range tests {
@@ -242,17 +314,18 @@ func TestServer_GetTranscript_Validation(t *testing.T) {
 	defer ts.Close()
 	client := ts.Client()
 
-	// Seed a transcript for success case
-	seedBody := `{"id":"v1", "streamer":"S1", "date":"2023-01-01"}`
-	req, _ := http.NewRequest("POST", ts.URL+"/transcript", strings.NewReader(seedBody))
-	req.Header.Set("X-API-Key", app.config.APIKey)
-	resp, err := client.Do(req)
-	if err != nil {
-		t.Fatalf("Failed to seed transcript: %v", err)
-	}
-	resp.Body.Close()
-	if resp.StatusCode != http.StatusCreated {
-		t.Fatalf("Failed to seed transcript, got status: %d", resp.StatusCode)
+	// Seed a transcript with two tracks for success case
+	for _, seedBody := range []string{`{"id":"v1", "streamer":"S1", "date":"2023-01-01"}`, `{"id":"v1", "streamer":"S1", "date":"2023-01-01", "lang":"ja"}`} {
+		req, _ := http.NewRequest("POST", ts.URL+"/transcript", strings.NewReader(seedBody))
+		req.Header.Set("X-API-Key", app.config.APIKey)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to seed transcript: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Failed to seed transcript, got status: %d", resp.StatusCode)
+		}
 	}
 
 	tests := []struct {
@@ -275,6 +348,91 @@ func TestServer_GetTranscript_Validation(t *testing.T) {
 			path:           "/transcript/",
 			expectedStatus: http.StatusNotFound,
 		},
+		{
+			name:           "Revision",
+			path:           "/transcript/v1?rev=1",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Missing Revision",
+			path:           "/transcript/v1?rev=3",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Invalid Revision",
+			path:           "/transcript/v1?rev=abc",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Zero Revision",
+			path:           "/transcript/v1?rev=0",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unknown Lang",
+			path:           "/transcript/v1?lang=fr",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Window",
+			path:           "/transcript/v1?from=00:01:00&to=00:02:00",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Around",
+			path:           "/transcript/v1?around=1:00:00&lines=5&rev=1",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Invalid From",
+			path:           "/transcript/v1?from=60",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "To Before From",
+			path:           "/transcript/v1?from=00:02:00&to=00:01:00",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Around With From",
+			path:           "/transcript/v1?around=00:01:00&from=00:00:00",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Lines Without Around",
+			path:           "/transcript/v1?lines=5",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Too Many Lines",
+			path:           "/transcript/v1?around=00:01:00&lines=501",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Revisions",
+			path:           "/transcript/v1/revisions",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Diff",
+			path:           "/transcript/v1/diff?from=1&to=1",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Diff Missing From",
+			path:           "/transcript/v1/diff",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Diff Missing Revision",
+			path:           "/transcript/v1/diff?from=5",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Diff Across Tracks",
+			path:           "/transcript/v1/diff?from=1&to=2",
+			expectedStatus: http.StatusBadRequest,
+		},
 	}
 
 	for _, tt := range tests {
@@ -329,6 +487,16 @@ func TestServer_GetGraphByID_Validation(t *testing.T) {
 			path:           "/graph/v1",
 			expectedStatus: http.StatusBadRequest,
 		},
+		{
+			name:           "Only Excluded Terms",
+			path:           "/graph/v1?searchText=-world",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Operators",
+			path:           "/graph/v1?searchText=hello+OR+%22good+night%22",
+			expectedStatus: http.StatusOK,
+		},
 	}
 
 	for _, tt := range tests {
@@ -346,6 +514,68 @@ func TestServer_GetGraphByID_Validation(t *testing.T) {
 	}
 }
 
+func TestServer_SearchTranscript(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	mux := http.NewServeMux()
+	app.InitServerEndpoints(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	client := ts.Client()
+
+	for _, body := range []string{
+		`{"id":"s1", "streamer":"S1", "date":"2023-01-01", "srt":"1\n00:00:01,000 --> 00:00:02,000\nHello world\n\n2\n00:00:03,000 --> 00:00:04,000\nworldwide world"}`,
+		`{"id":"s2", "streamer":"S1", "date":"2023-01-02", "streamType":"Members", "srt":"1\n00:00:01,000 --> 00:00:02,000\nHello world"}`,
+	} {
+		req, _ := http.NewRequest("POST", ts.URL+"/transcript", strings.NewReader(body))
+		req.Header.Set("X-API-Key", app.config.APIKey)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to seed transcript: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Failed to seed transcript, got status: %d", resp.StatusCode)
+		}
+	}
+
+	tests := []struct {
+		path           string
+		expectedStatus int
+	}{
+		{"/transcript/s1/search?searchText=world", http.StatusOK},
+		{"/transcript/s1/search", http.StatusBadRequest},
+		{"/transcript/s1/search?searchText=-world", http.StatusBadRequest},
+		{"/transcript/s1/search?searchText=wor%5B&searchMode=regex", http.StatusBadRequest},
+		{"/transcript/s1/search?searchText=world&lang=fr", http.StatusNotFound},
+		{"/transcript/s2/search?searchText=world", http.StatusNotFound},
+		{"/transcript/missing/search?searchText=world", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		resp, err := client.Get(ts.URL + tt.path)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.expectedStatus {
+			t.Errorf("GET %s: expected status %d, got %d", tt.path, tt.expectedStatus, resp.StatusCode)
+		}
+	}
+
+	resp, err := client.Get(ts.URL + "/transcript/s1/search?searchText=world&matchWholeWord=true")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var output TranscriptMatchesOutput
+	if err := json.NewDecoder(resp.Body).Decode(&output); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if output.Total != 2 || output.Matches[0].ID != "0" || output.Matches[1].ID != "1" || !slices.Equal(output.Matches[1].Highlights, []HighlightRange{{10, 15}}) {
+		t.Errorf("Unexpected matches: %+v", output)
+	}
+}
+
 func TestServer_GetGraphAll_Validation(t *testing.T) {
 	app := setupTestApp(t)
 	defer app.db.Close()
@@ -383,6 +613,16 @@ func TestServer_GetGraphAll_Validation(t *testing.T) {
 			path:           "/graph",
 			expectedStatus: http.StatusBadRequest,
 		},
+		{
+			name:           "Only Excluded Terms",
+			path:           "/graph?searchText=-world",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Operators",
+			path:           "/graph?searchText=hello+OR+%22good+night%22",
+			expectedStatus: http.StatusOK,
+		},
 	}
 
 	for _, tt := range tests {
@@ -542,3 +782,611 @@ func TestServer_HandlePostTranscript_Zstd(t *testing.T) {
 		t.Errorf("Expected streamer 'StreamerZstd', got '%s'", tr.Streamer)
 	}
 }
+
+func TestServer_DecompressionMiddleware_MaxBytes(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+
+	gzipped := func(data []byte) []byte {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		gw.Write(data)
+		gw.Close()
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name        string
+		body        []byte
+		encoding    string
+		wantTooLong bool
+	}{
+		{"Small", bytes.Repeat([]byte("a"), 1000), "", false},
+		{"Large", bytes.Repeat([]byte("a"), 2000), "", true},
+		{"Small gzip", gzipped(bytes.Repeat([]byte("a"), 1000)), "gzip", false},
+		{"Gzip bomb", gzipped(make([]byte, 1<<20)), "gzip", true}, // Compresses to about 1 KB
+	}
+	for _, tt := range tests {
+		var readErr error
+		handler := app.decompressionMiddleware(1024, func(w http.ResponseWriter, r *http.Request) {
+			_, readErr = io.ReadAll(r.Body)
+		})
+		req := httptest.NewRequest("POST", "/transcript", bytes.NewReader(tt.body))
+		if tt.encoding != "" {
+			req.Header.Set("Content-Encoding", tt.encoding)
+		}
+		handler(httptest.NewRecorder(), req)
+
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(readErr, &maxBytesErr) != tt.wantTooLong {
+			t.Errorf("%s: expected too long=%v, got %v", tt.name, tt.wantTooLong, readErr)
+		}
+	}
+}
+
+func TestServer_HandlePostTranscript_VTT(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	mux := http.NewServeMux()
+	app.InitServerEndpoints(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	client := ts.Client()
+
+	// The same content uploaded as SRT and as VTT should produce identical lines
+	srtBody := `{"id":"srt-id", "streamer":"S1", "date":"2023-01-01", "srt":"1\n00:00:01,000 --> 00:00:02,000\n[S1]: Hello world\n\n2\n00:00:03,000 --> 00:00:04,000\nSecond line"}`
+	vttBody := `{"id":"vtt-id", "streamer":"S1", "date":"2023-01-01", "format":"vtt", "vtt":"WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.000 align:start\n<v S1>Hello world</v>\n\nNOTE skipped\n\n00:00:03.000 --> 00:00:04.000\n<c>Second</c> line"}`
+
+	for _, body := range []string{srtBody, vttBody} {
+		req, _ := http.NewRequest("POST", ts.URL+"/transcript", strings.NewReader(body))
+		req.Header.Set("X-API-Key", app.config.APIKey)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected 201 Created, got %d", resp.StatusCode)
+		}
+	}
+
+	ctx := context.Background()
+	srtTr, _, err := app.retrieveTranscript(ctx, "srt-id")
+	if err != nil {
+		t.Fatalf("Failed to retrieve srt transcript: %v", err)
+	}
+	vttTr, _, err := app.retrieveTranscript(ctx, "vtt-id")
+	if err != nil {
+		t.Fatalf("Failed to retrieve vtt transcript: %v", err)
+	}
+
+	if len(vttTr.TranscriptLines) != 2 {
+		t.Fatalf("Expected 2 vtt lines, got %d", len(vttTr.TranscriptLines))
+	}
+	for i := range srtTr.TranscriptLines {
+		if srtTr.TranscriptLines[i] != vttTr.TranscriptLines[i] {
+			t.Errorf("Line %d mismatch: srt %v, vtt %v", i, srtTr.TranscriptLines[i], vttTr.TranscriptLines[i])
+		}
+	}
+}
+
+func TestServer_PostTranscript_Strict(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	mux := http.NewServeMux()
+	app.InitServerEndpoints(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	client := ts.Client()
+
+	goodBody := `{"id":"good", "streamer":"S1", "date":"2023-01-01", "srt":"1\n00:00:01,000 --> 00:00:02,000\nHello"}`
+	badBody := `{"id":"bad", "streamer":"S1", "date":"2023-01-01", "srt":"1\n00:00:01,000 --> 00:00:02,000\nHello\n\n2\nBAD --> BAD\nBroken"}`
+
+	post := func(path, body string) (*http.Response, ValidationOutput) {
+		req, _ := http.NewRequest("POST", ts.URL+path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", app.config.APIKey)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var out ValidationOutput
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp, out
+	}
+
+	countTranscripts := func(id string) int {
+		count := 0
+		app.db.QueryRow("SELECT COUNT(*) FROM transcripts WHERE id = ?", id).Scan(&count)
+		return count
+	}
+
+	t.Run("Validate reports rejected blocks without saving", func(t *testing.T) {
+		resp, out := post("/transcript/validate", badBody)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", resp.StatusCode)
+		}
+		if out.Valid || out.Lines != 1 || len(out.Rejected) != 1 {
+			t.Fatalf("Unexpected validation output: %+v", out)
+		}
+		if out.Rejected[0].Index != 2 || out.Rejected[0].Reason != "invalid timestamp line" || out.Rejected[0].Raw != "2\nBAD --> BAD\nBroken" {
+			t.Errorf("Unexpected rejected block: %+v", out.Rejected[0])
+		}
+		if countTranscripts("bad") != 0 {
+			t.Error("Validate should not save the transcript")
+		}
+	})
+
+	t.Run("Validate requires API key", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", ts.URL+"/transcript/validate", strings.NewReader(goodBody))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected 401, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Strict rejects invalid upload", func(t *testing.T) {
+		resp, out := post("/transcript?strict=true", badBody)
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Fatalf("Expected 422, got %d", resp.StatusCode)
+		}
+		if out.Valid || len(out.Rejected) != 1 {
+			t.Errorf("Unexpected validation output: %+v", out)
+		}
+		if countTranscripts("bad") != 0 {
+			t.Error("Strict upload with invalid blocks should not be saved")
+		}
+	})
+
+	t.Run("Strict accepts valid upload", func(t *testing.T) {
+		resp, _ := post("/transcript?strict=true", goodBody)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d", resp.StatusCode)
+		}
+		if countTranscripts("good") != 1 {
+			t.Error("Strict upload with valid blocks should be saved")
+		}
+	})
+
+	t.Run("Non-strict skips invalid blocks", func(t *testing.T) {
+		resp, _ := post("/transcript", badBody)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d", resp.StatusCode)
+		}
+		if countTranscripts("bad") != 1 {
+			t.Error("Non-strict upload should be saved")
+		}
+	})
+}
+
+func TestServer_BulkPostTranscripts(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	mux := http.NewServeMux()
+	app.InitServerEndpoints(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	client := ts.Client()
+
+	var ndjson strings.Builder
+	ndjson.WriteString(`{"id":"b1", "streamer":"S1", "date":"2023-01-01", "srt":"1\n00:00:01,000 --> 00:00:02,000\nHello"}` + "\n")
+	ndjson.WriteString(`{"id":"b2", "streamer":"S1", "date":"2023-01-02", "format":"vtt", "vtt":"WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nWorld"}` + "\n")
+	ndjson.WriteString("\n") // Blank lines are skipped
+	ndjson.WriteString(`{"id":"b3", "streamer":"S1"` + "\n")
+	ndjson.WriteString(`{"id":"b4", "streamer":"S1"}` + "\n")
+	ndjson.WriteString(`{"id":"b5", "streamer":"S1", "date":"2023-01-05", "srt":""}` + "\n")
+	ndjson.WriteString(`{"id":"b1", "streamer":"S1", "date":"2023-01-01", "srt":"1\n00:00:01,000 --> 00:00:02,000\nHello"}`) // No trailing newline
+
+	// Compress payload
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write([]byte(ndjson.String()))
+	gw.Close()
+
+	req, _ := http.NewRequest("POST", ts.URL+"/transcripts/bulk", &buf)
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("X-API-Key", app.config.APIKey)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	wantResults := []BulkResult{
+		{Line: 1, ID: "b1", Status: TranscriptStatusCreated},
+		{Line: 2, ID: "b2", Status: TranscriptStatusCreated},
+		{Line: 4, Status: TranscriptStatusError, Error: "Invalid JSON"},
+		{Line: 5, ID: "b4", Status: TranscriptStatusError, Error: "Missing required fields: id, streamer, date"},
+		{Line: 6, ID: "b5", Status: TranscriptStatusCreated},
+		{Line: 7, ID: "b1", Status: TranscriptStatusUnchanged},
+	}
+	for i, want := range wantResults {
+		var got BulkResult
+		if err := decoder.Decode(&got); err != nil {
+			t.Fatalf("Failed to decode result %d: %v", i, err)
+		}
+		if got != want {
+			t.Errorf("Result %d: got %+v, want %+v", i, got, want)
+		}
+	}
+	var summary BulkSummary
+	if err := decoder.Decode(&summary); err != nil {
+		t.Fatalf("Failed to decode summary: %v", err)
+	}
+	if !summary.Done || summary.Created != 3 || summary.Unchanged != 1 || summary.Failed != 2 {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+
+	// Verify saved transcripts
+	ctx := context.Background()
+	tr, _, err := app.retrieveTranscript(ctx, "b2")
+	if err != nil {
+		t.Fatalf("Failed to retrieve b2: %v", err)
+	}
+	if len(tr.TranscriptLines) != 1 || tr.TranscriptLines[0].Text != "World" {
+		t.Errorf("Unexpected b2 lines: %v", tr.TranscriptLines)
+	}
+	count := 0
+	app.db.QueryRow("SELECT COUNT(*) FROM transcripts").Scan(&count)
+	if count != 3 {
+		t.Errorf("Expected 3 transcripts, got %d", count)
+	}
+}
+
+func TestServer_BulkPostTranscripts_ManyBatches(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	mux := http.NewServeMux()
+	app.InitServerEndpoints(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	client := ts.Client()
+
+	total := bulkBatchSize*2 + 7
+	var ndjson strings.Builder
+	for i := range total {
+		fmt.Fprintf(&ndjson, `{"id":"m%d", "streamer":"S1", "date":"2023-01-01", "srt":"1\n00:00:01,000 --> 00:00:02,000\nline %d"}`+"\n", i, i)
+	}
+
+	req, _ := http.NewRequest("POST", ts.URL+"/transcripts/bulk", strings.NewReader(ndjson.String()))
+	req.Header.Set("X-API-Key", app.config.APIKey)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for i := range total {
+		var got BulkResult
+		if err := decoder.Decode(&got); err != nil {
+			t.Fatalf("Failed to decode result %d: %v", i, err)
+		}
+		if got.Line != i+1 || got.Status != TranscriptStatusCreated {
+			t.Errorf("Result %d: unexpected %+v", i, got)
+		}
+	}
+	var summary BulkSummary
+	decoder.Decode(&summary)
+	if !summary.Done || summary.Created != total || summary.Failed != 0 {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+
+	count := 0
+	app.db.QueryRow("SELECT COUNT(*) FROM transcripts").Scan(&count)
+	if count != total {
+		t.Errorf("Expected %d transcripts, got %d", total, count)
+	}
+}
+
+func TestServer_DeleteTranscript(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	mux := http.NewServeMux()
+	app.InitServerEndpoints(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	client := ts.Client()
+
+	seedBody := `{"id":"d1", "streamer":"S1", "date":"2023-01-01", "srt":"1\n00:00:01,000 --> 00:00:02,000\nHello world"}`
+	req, _ := http.NewRequest("POST", ts.URL+"/transcript", strings.NewReader(seedBody))
+	req.Header.Set("X-API-Key", app.config.APIKey)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to seed transcript: %v", err)
+	}
+	resp.Body.Close()
+
+	tests := []struct {
+		name   string
+		apiKey string
+		id     string
+		code   int
+	}{
+		{"Missing API key", "", "d1", http.StatusUnauthorized},
+		{"Success", app.config.APIKey, "d1", http.StatusNoContent},
+		{"Already deleted", app.config.APIKey, "d1", http.StatusNotFound},
+		{"Unknown ID", app.config.APIKey, "nope", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest("DELETE", ts.URL+"/transcript/"+test.id, nil)
+		req.Header.Set("X-API-Key", test.apiKey)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", test.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.code {
+			t.Errorf("%s: expected %d, got %d", test.name, test.code, resp.StatusCode)
+		}
+	}
+
+	// The lines and search index are cleaned up with the transcript.
+	lineCount := 0
+	app.db.QueryRow("SELECT COUNT(*) FROM transcript_lines").Scan(&lineCount)
+	if lineCount != 0 {
+		t.Errorf("Expected 0 transcript lines, got %d", lineCount)
+	}
+	res, err := app.queryTranscripts(context.Background(), QueryData{SearchText: "hello"})
+	if err != nil || len(res.Result) != 0 {
+		t.Errorf("Deleted transcript still searchable: %+v, %v", res, err)
+	}
+}
+
+func TestServer_PatchTranscript(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	mux := http.NewServeMux()
+	app.InitServerEndpoints(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	client := ts.Client()
+
+	seedBody := `{"id":"p1", "streamer":"S1", "date":"2023-01-01", "streamType":"Stream", "streamTitle":"Tpyo", "srt":"1\n00:00:01,000 --> 00:00:02,000\nHello world"}`
+	req, _ := http.NewRequest("POST", ts.URL+"/transcript", strings.NewReader(seedBody))
+	req.Header.Set("X-API-Key", app.config.APIKey)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to seed transcript: %v", err)
+	}
+	resp.Body.Close()
+
+	tests := []struct {
+		name   string
+		apiKey string
+		id     string
+		body   string
+		code   int
+	}{
+		{"Missing API key", "", "p1", `{"streamTitle":"Typo"}`, http.StatusUnauthorized},
+		{"Invalid body", app.config.APIKey, "p1", `{"streamTitle":`, http.StatusBadRequest},
+		{"Nothing to update", app.config.APIKey, "p1", `{}`, http.StatusBadRequest},
+		{"Empty streamer", app.config.APIKey, "p1", `{"streamer":""}`, http.StatusBadRequest},
+		{"Invalid date", app.config.APIKey, "p1", `{"date":"2023/01/02"}`, http.StatusBadRequest},
+		{"Unknown ID", app.config.APIKey, "nope", `{"streamTitle":"Typo"}`, http.StatusNotFound},
+		{"Title", app.config.APIKey, "p1", `{"streamTitle":"Typo"}`, http.StatusOK},
+		{"Date and type", app.config.APIKey, "p1", `{"date":"2023-01-02","streamType":"Video"}`, http.StatusOK},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest("PATCH", ts.URL+"/transcript/"+test.id, strings.NewReader(test.body))
+		req.Header.Set("X-API-Key", test.apiKey)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", test.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.code {
+			t.Errorf("%s: expected %d, got %d", test.name, test.code, resp.StatusCode)
+		}
+	}
+
+	tr, _, err := app.retrieveTranscript(context.Background(), "p1")
+	if err != nil {
+		t.Fatalf("Failed to retrieve p1: %v", err)
+	}
+	want := TranscriptOutput{ID: "p1", Streamer: "S1", Date: "2023-01-02", StreamType: "Video", StreamTitle: "Typo"}
+	if tr.ID != want.ID || tr.Streamer != want.Streamer || tr.Date != want.Date || tr.StreamType != want.StreamType || tr.StreamTitle != want.StreamTitle {
+		t.Errorf("Unexpected metadata: got %+v, want %+v", tr, want)
+	}
+	if len(tr.TranscriptLines) != 1 {
+		t.Errorf("Expected lines to be kept, got %v", tr.TranscriptLines)
+	}
+}
+
+func TestServer_PatchTranscriptLine(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	mux := http.NewServeMux()
+	app.InitServerEndpoints(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	client := ts.Client()
+
+	seedBody := `{"id":"c1", "streamer":"S1", "date":"2023-01-01", "srt":"1\n00:00:05,000 --> 00:00:06,000\nplaying mine craft\n\n2\n00:00:01,000 --> 00:00:02,000\nhello chat"}`
+	req, _ := http.NewRequest("POST", ts.URL+"/transcript", strings.NewReader(seedBody))
+	req.Header.Set("X-API-Key", app.config.APIKey)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to seed transcript: %v", err)
+	}
+	resp.Body.Close()
+
+	tests := []struct {
+		name   string
+		apiKey string
+		path   string
+		body   string
+		code   int
+	}{
+		{"Missing API key", "", "/transcript/c1/lines/1", `{"text":"playing Minecraft","editor":"duck"}`, http.StatusUnauthorized},
+		{"Invalid body", app.config.APIKey, "/transcript/c1/lines/1", `{"text":`, http.StatusBadRequest},
+		{"Missing editor", app.config.APIKey, "/transcript/c1/lines/1", `{"text":"playing Minecraft"}`, http.StatusBadRequest},
+		{"Empty text", app.config.APIKey, "/transcript/c1/lines/1", `{"text":"  ","editor":"duck"}`, http.StatusBadRequest},
+		{"Unknown line", app.config.APIKey, "/transcript/c1/lines/2", `{"text":"playing Minecraft","editor":"duck"}`, http.StatusNotFound},
+		{"Invalid line ID", app.config.APIKey, "/transcript/c1/lines/abc", `{"text":"playing Minecraft","editor":"duck"}`, http.StatusNotFound},
+		{"Unknown transcript", app.config.APIKey, "/transcript/nope/lines/0", `{"text":"playing Minecraft","editor":"duck"}`, http.StatusNotFound},
+		{"Success", app.config.APIKey, "/transcript/c1/lines/1", `{"text":"playing Minecraft","editor":"duck"}`, http.StatusOK},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest("PATCH", ts.URL+test.path, strings.NewReader(test.body))
+		req.Header.Set("X-API-Key", test.apiKey)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", test.name, err)
+		}
+		if resp.StatusCode != test.code {
+			t.Errorf("%s: expected %d, got %d", test.name, test.code, resp.StatusCode)
+		}
+		if test.code == http.StatusOK {
+			var line TranscriptLine
+			json.NewDecoder(resp.Body).Decode(&line)
+			want := TranscriptLine{ID: "1", Start: "00:00:05", StartMs: 5000, EndMs: 6000, Text: "playing Minecraft"}
+			if line != want {
+				t.Errorf("%s: got %+v, want %+v", test.name, line, want)
+			}
+		}
+		resp.Body.Close()
+	}
+
+	// The corrected text replaces the old text in the transcript and the search index.
+	ctx := context.Background()
+	tr, _, err := app.retrieveTranscript(ctx, "c1")
+	if err != nil {
+		t.Fatalf("Failed to retrieve c1: %v", err)
+	}
+	if tr.TranscriptLines[1].Text != "playing Minecraft" {
+		t.Errorf("Expected corrected line, got %+v", tr.TranscriptLines)
+	}
+	res, err := app.queryTranscripts(ctx, QueryData{SearchText: "minecraft"})
+	if err != nil || len(res.Result) != 1 {
+		t.Errorf("Expected corrected text to be searchable, got %+v, %v", res, err)
+	}
+	res, err = app.queryTranscripts(ctx, QueryData{SearchText: "mine craft"})
+	if err != nil || len(res.Result) != 0 {
+		t.Errorf("Expected old text to be gone from search, got %+v, %v", res, err)
+	}
+
+	// The edit is logged.
+	var revision int
+	var lineID, oldText, newText, editor, editedAt string
+	err = app.db.QueryRow("SELECT revision, line_id, old_text, new_text, editor, edited_at FROM transcript_line_edits WHERE transcript_id = 'c1'").
+		Scan(&revision, &lineID, &oldText, &newText, &editor, &editedAt)
+	if err != nil {
+		t.Fatalf("Failed to read edit log: %v", err)
+	}
+	if revision != 1 || lineID != "1" || oldText != "playing mine craft" || newText != "playing Minecraft" || editor != "duck" || editedAt == "" {
+		t.Errorf("Unexpected edit log: %d %s %q %q %s %s", revision, lineID, oldText, newText, editor, editedAt)
+	}
+
+	// The edit log is public.
+	for path, code := range map[string]int{"/transcript/c1/edits": http.StatusOK, "/transcript/nope/edits": http.StatusNotFound, "/transcript/c1/edits?lang=ja": http.StatusNotFound} {
+		resp, err := client.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", path, err)
+		}
+		if resp.StatusCode != code {
+			t.Errorf("%s: expected %d, got %d", path, code, resp.StatusCode)
+		}
+		if code == http.StatusOK {
+			var edits TranscriptEditsOutput
+			json.NewDecoder(resp.Body).Decode(&edits)
+			if len(edits.Edits) != 1 || edits.Edits[0].LineID != "1" || edits.Edits[0].Editor != "duck" {
+				t.Errorf("%s: unexpected edits %+v", path, edits)
+			}
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestServer_SearchTranscripts_InvalidSearchText(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	mux := http.NewServeMux()
+	app.InitServerEndpoints(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	for _, searchText := range []string{"-world", "%21%21%21", "a+NEAR%2F500+b", "a+NEAR+b&near=-1", "a+NEAR+b&near=101", "a+NEAR+b&near=abc",
+		"%28&searchMode=regex", "a*&searchMode=regex", "a%28%3F%3Db%29&searchMode=regex", "hello&searchMode=bogus", "a+NEAR+b&searchMode=fuzzy"} {
+		resp, err := ts.Client().Get(ts.URL + "/transcripts?searchText=" + searchText)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("searchText=%s: expected status %d, got %d", searchText, http.StatusBadRequest, resp.StatusCode)
+		}
+	}
+
+	// near accepts the same distances as NEAR/N.
+	for _, searchText := range []string{"a+NEAR%2F0+b", "a+NEAR+b&near=0", "a+NEAR%2F100+b", "a+NEAR+b&near=100"} {
+		resp, err := ts.Client().Get(ts.URL + "/transcripts?searchText=" + searchText)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("searchText=%s: expected status %d, got %d", searchText, http.StatusOK, resp.StatusCode)
+		}
+	}
+
+	// Operators have no meaning in regex mode.
+	resp, err := ts.Client().Get(ts.URL + "/transcripts?searchText=-world&searchMode=regex")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status %d for a regex search, got %d", http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestServer_SearchTranscripts_InvalidPage(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.db.Close()
+	mux := http.NewServeMux()
+	app.InitServerEndpoints(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	tests := []struct {
+		query string
+		code  int
+	}{
+		{"limit=10&contextLimit=5&contextOffset=5", http.StatusOK},
+		{"limit=0", http.StatusBadRequest},
+		{"limit=201", http.StatusBadRequest},
+		{"contextLimit=abc", http.StatusBadRequest},
+		{"contextLimit=101", http.StatusBadRequest},
+		{"contextOffset=-1", http.StatusBadRequest},
+		{"cursor=abc", http.StatusBadRequest},
+		{"sort=relevance&limit=1", http.StatusOK},
+		{"sort=popularity", http.StatusBadRequest},
+		{"before=2&after=10", http.StatusOK},
+		{"before=-1", http.StatusBadRequest},
+		{"after=11", http.StatusBadRequest},
+		{"fromOffset=00:00:00&toOffset=00:30:00", http.StatusOK},
+		{"fromOffset=30", http.StatusBadRequest},
+		{"facets=streamer,year&facets=month", http.StatusOK},
+		{"facets=streamer,title", http.StatusBadRequest},
+		{"fromOffset=00:30:00&toOffset=00:10:00", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		resp, err := ts.Client().Get(ts.URL + "/transcripts?searchText=hello&" + test.query)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.code {
+			t.Errorf("%s: expected status %d, got %d", test.query, test.code, resp.StatusCode)
+		}
+	}
+}
@@ -51,7 +51,104 @@ type TranscriptInput struct {
 	StreamType    string `json:"streamType"`
 	StreamTitle   string `json:"streamTitle"`
 	ID            string `json:"id"`
+	Format        string `json:"format"` // "srt" or "vtt". Inferred from the populated transcript field when empty.
+	Lang          string `json:"lang"`   // Language of the track, such as "en" or "ja". Each language of a stream is stored separately.
 	SrtTranscript string `json:"srt"`
+	VttTranscript string `json:"vtt"`
+}
+
+// Selects the track uploaded without a language, which is listed with an empty lang.
+// An empty lang parameter selects the default track instead, which is the first track uploaded.
+const untaggedLang = "und"
+
+// TranscriptPatch is the structure for the PATCH /transcript/:id request. Fields left out are not changed.
+type TranscriptPatch struct {
+	Streamer    *string `json:"streamer"`
+	Date        *string `json:"date"` // YYYY-MM-DD
+	StreamType  *string `json:"streamType"`
+	StreamTitle *string `json:"streamTitle"`
+}
+
+// LineCorrectionInput is the structure for the PATCH /transcript/:id/lines/:lineId request.
+type LineCorrectionInput struct {
+	Text   string `json:"text"`
+	Editor string `json:"editor"` // Who made the correction, kept in the edit log
+}
+
+// Outcomes of saving a transcript, used by POST /transcript and BulkResult.Status.
+const (
+	TranscriptStatusCreated   = "created"   // Lines were (re)written
+	TranscriptStatusUpdated   = "updated"   // Content was unchanged, only the metadata was written
+	TranscriptStatusUnchanged = "unchanged" // Content and metadata were unchanged, nothing was written
+	TranscriptStatusError     = "error"
+)
+
+// Kinds of transcript revision, used by TranscriptRevision.Kind.
+const (
+	RevisionKindUpload     = "upload"     // Content uploaded with POST /transcript
+	RevisionKindCorrection = "correction" // Lines of the track after a correction, or after corrections were kept across an upload
+)
+
+// Supported values for QueryData.SearchMode.
+const (
+	SearchModeText  = "text"  // Search text is parsed by parseSearchQuery
+	SearchModeRegex = "regex" // Search text is an RE2 pattern matched against the original text of each line
+	SearchModeFuzzy = "fuzzy" // Like text, but words also match close misspellings found in the transcripts
+)
+
+// Supported values for QueryData.Sort.
+const (
+	SearchSortNewest    = "newest"    // Latest streams first, the default
+	SearchSortOldest    = "oldest"    // Earliest streams first
+	SearchSortRelevance = "relevance" // Highest TranscriptSearch.Score first
+	SearchSortMatches   = "matches"   // Most matching lines first
+	SearchSortTitle     = "title"     // Titles in alphabetical order, ignoring case
+)
+
+// Supported values for QueryData.Facets.
+const (
+	SearchFacetStreamer   = "streamer"
+	SearchFacetStreamType = "streamType"
+	SearchFacetYear       = "year"  // "YYYY"
+	SearchFacetMonth      = "month" // "YYYY-MM"
+)
+
+// Supported values for TranscriptInput.Format.
+const (
+	TranscriptFormatSRT = "srt"
+	TranscriptFormatVTT = "vtt"
+)
+
+// ValidationOutput is the response for POST /transcript/validate, and for POST /transcript?strict=true when it is rejected.
+type ValidationOutput struct {
+	Valid    bool            `json:"valid"`
+	Lines    int             `json:"lines"` // Number of lines that parsed successfully
+	Rejected []RejectedBlock `json:"rejected"`
+}
+
+// RejectedBlock is a single SRT block or VTT cue that could not be parsed.
+type RejectedBlock struct {
+	Index  int    `json:"index"` // 1-based position of the block in the uploaded file, not counting extra blank lines
+	Reason string `json:"reason"`
+	Raw    string `json:"raw"`
+}
+
+// BulkResult is streamed back by POST /transcripts/bulk, one per NDJSON input line.
+type BulkResult struct {
+	Line   int    `json:"line"` // 1-based line number in the request body
+	ID     string `json:"id"`
+	Status string `json:"status"` // "created", "updated", "unchanged", or "error"
+	Error  string `json:"error,omitempty"`
+}
+
+// BulkSummary is the final line streamed back by POST /transcripts/bulk.
+type BulkSummary struct {
+	Done      bool   `json:"done"`
+	Created   int    `json:"created"`
+	Updated   int    `json:"updated"`
+	Unchanged int    `json:"unchanged"`
+	Failed    int    `json:"failed"`
+	Error     string `json:"error,omitempty"` // Set if the request body could not be read to the end
 }
 
 // TranscriptOutput is the structure for the GET /transcript/:id response.
@@ -61,12 +158,119 @@ type TranscriptOutput struct {
 	StreamType      string           `json:"streamType"`
 	StreamTitle     string           `json:"streamTitle"`
 	ID              string           `json:"id"`
+	Lang            string           `json:"lang"`       // Language of the returned track
+	Langs           []string         `json:"langs"`      // Languages of all tracks, the default track first. Empty for the track without a language.
+	Revision        int              `json:"revision"`   // 0 if the transcript has no stored revisions
+	TotalLines      int              `json:"totalLines"` // Lines in the track, of which TranscriptLines is a window with from, to or around
 	TranscriptLines []TranscriptLine `json:"transcriptLines"`
 }
 
+// TranscriptWindow selects the lines of a transcript returned by GET /transcript/:id. Times are in milliseconds.
+// Lines keep the IDs they have in the whole track.
+type TranscriptWindow struct {
+	From   *int64 // Lines starting at or after From
+	To     *int64 // Lines starting before the end of the second at To
+	Around *int64 // The line being said at Around, and Lines lines on each side of it
+	Lines  int
+}
+
+// TranscriptRevisionsOutput is the response for the GET /transcript/:id/revisions request.
+type TranscriptRevisionsOutput struct {
+	ID        string               `json:"id"`
+	Current   int                  `json:"current"`   // Latest revision of any track
+	Revisions []TranscriptRevision `json:"revisions"` // Oldest first
+}
+
+// TranscriptRevision describes one stored upload or correction of a transcript.
+type TranscriptRevision struct {
+	Revision  int    `json:"revision"`
+	Lang      string `json:"lang"`
+	Kind      string `json:"kind"` // RevisionKindUpload or RevisionKindCorrection
+	Format    string `json:"format"`
+	Lines     int    `json:"lines"`
+	CreatedAt string `json:"createdAt"` // RFC3339
+}
+
+// TranscriptDiffOutput is the response for the GET /transcript/:id/diff request.
+type TranscriptDiffOutput struct {
+	ID        string       `json:"id"`
+	From      int          `json:"from"`
+	To        int          `json:"to"`
+	Added     int          `json:"added"`
+	Removed   int          `json:"removed"`
+	Unchanged int          `json:"unchanged"`
+	Changes   []LineChange `json:"changes"`
+}
+
+// LineChange is a single line that was added or removed between two revisions.
+// Removed lines have their ID and times from the older revision, added lines from the newer one.
+type LineChange struct {
+	Op   string         `json:"op"` // "added" or "removed"
+	Line TranscriptLine `json:"line"`
+}
+
+// TranscriptEditsOutput is the response for the GET /transcript/:id/edits request.
+type TranscriptEditsOutput struct {
+	ID    string     `json:"id"`
+	Lang  string     `json:"lang"`
+	Edits []LineEdit `json:"edits"` // Oldest first
+}
+
+// LineEdit is a single correction made to a line with PATCH /transcript/:id/lines/:lineId.
+type LineEdit struct {
+	LineID   string `json:"lineId"`   // Same as TranscriptLine.ID
+	Revision int    `json:"revision"` // Revision of the track that was corrected
+	OldText  string `json:"oldText"`
+	NewText  string `json:"newText"`
+	Editor   string `json:"editor"`
+	EditedAt string `json:"editedAt"` // RFC3339
+}
+
+// Supported values for LineChange.Op.
+const (
+	LineChangeAdded   = "added"
+	LineChangeRemoved = "removed"
+)
+
 // TranscriptSearchOutput is the response for the GET /transcripts search.
 type TranscriptSearchOutput struct {
-	Result []*TranscriptSearch `json:"result"`
+	Result        []*TranscriptSearch     `json:"result"`
+	Total         int                     `json:"total"`                 // Matching transcripts on every page
+	TotalContexts int                     `json:"totalContexts"`         // Matching lines on every page. 0 without search text.
+	NextCursor    string                  `json:"nextCursor,omitempty"`  // Cursor of the next page, empty on the last page
+	Fuzzy         []FuzzyTerm             `json:"fuzzy,omitempty"`       // Expansions of the search terms in fuzzy mode
+	Suggestions   []string                `json:"suggestions,omitempty"` // Search texts with misspelled words corrected, when nothing matched
+	Facets        map[string][]FacetCount `json:"facets,omitempty"`      // Counts for each value of the requested facets, over every page
+}
+
+// FacetCount is the number of transcripts and lines matching a search that have one value of a facet.
+type FacetCount struct {
+	Value       string `json:"value"`
+	Transcripts int    `json:"transcripts"`
+	Matches     int    `json:"matches"` // Matching lines. 0 without search text.
+}
+
+// FuzzyTerm is a term of a fuzzy search and the words or phrases it was expanded to.
+type FuzzyTerm struct {
+	Term     string   `json:"term"`
+	Variants []string `json:"variants"` // Searched variants that have results under the filters, the term itself first
+	Matched  []string `json:"matched"`  // Variants found in the returned contexts
+}
+
+// TranscriptMatchesOutput is the response for the GET /transcript/:id/search request.
+type TranscriptMatchesOutput struct {
+	ID      string      `json:"id"`
+	Lang    string      `json:"lang"`  // Language of the searched track
+	Total   int         `json:"total"` // Number of matching lines
+	Matches []LineMatch `json:"matches"`
+}
+
+// LineMatch is a line of a transcript that matches the search text.
+type LineMatch struct {
+	ID         string           `json:"id"`         // Same as TranscriptLine.ID
+	Start      string           `json:"start"`      // hh:mm:ss
+	StartMs    int64            `json:"startMs"`    // milliseconds from the start of the stream
+	Highlights []HighlightRange `json:"highlights"` // Matches of the search text in the text of the line
 }
 
 // GraphOutput is the response for the GET /graph and GET /graph/:id response.
@@ -84,25 +288,48 @@ type StreamMetadataOutput struct {
 
 // TranscriptSearch is the data for a single transcript that matches the criteria
 type TranscriptSearch struct {
-	ID         string          `json:"id"`
-	Streamer   string          `json:"streamer"`
-	Date       string          `json:"date"` // YYYY-MM-DD
-	StreamType string          `json:"streamType"`
-	Title      string          `json:"title"`
-	Contexts   []SearchContext `json:"contexts"`
+	ID            string          `json:"id"`
+	Streamer      string          `json:"streamer"`
+	Date          string          `json:"date"` // YYYY-MM-DD
+	StreamType    string          `json:"streamType"`
+	Title         string          `json:"title"`
+	TotalContexts int             `json:"totalContexts"` // Matching lines, of which a page is returned in Contexts
+	Score         float64         `json:"score"`         // Relevance to the search text, summed over the matching lines. Higher is better.
+	Contexts      []SearchContext `json:"contexts"`
 }
 
 // SearchContext is returned in the /transcripts search results.
 type SearchContext struct {
+	StartTime  string           `json:"startTime"`
+	Lang       string           `json:"lang,omitempty"`
+	Speaker    string           `json:"speaker,omitempty"`
+	Line       string           `json:"line"`             // Original text of the line, or a snippet of it with snippet=true
+	Highlights []HighlightRange `json:"highlights"`       // Matches of the search text in Line
+	Before     []ContextLine    `json:"before,omitempty"` // Lines before this one in the same track, earliest first
+	After      []ContextLine    `json:"after,omitempty"`  // Lines after this one in the same track, earliest first
+}
+
+// ContextLine is a line next to a SearchContext.
+type ContextLine struct {
 	StartTime string `json:"startTime"`
+	Speaker   string `json:"speaker,omitempty"`
 	Line      string `json:"line"`
 }
 
+// HighlightRange is a match of the search text in a line, in characters (Unicode code points) from the start of the line.
+type HighlightRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"` // Exclusive
+}
+
 // TranscriptLine is the structure for a single line of a transcript.
 type TranscriptLine struct {
-	ID    string `json:"id"`
-	Start string `json:"start"` // hh:mm:ss
-	Text  string `json:"text"`
+	ID      string `json:"id"`
+	Start   string `json:"start"`   // hh:mm:ss
+	StartMs int64  `json:"startMs"` // milliseconds from the start of the stream
+	EndMs   int64  `json:"endMs"`   // milliseconds from the start of the stream
+	Speaker string `json:"speaker,omitempty"`
+	Text    string `json:"text"`
 }
 
 // GraphDataPoint is a generic struct for all graph data.
@@ -117,14 +344,33 @@ type KeyResponse struct {
 }
 
 type QueryData struct {
-	SearchText        string
-	MatchWholeWord    bool
-	Streamer          string
-	StreamTitle       string
-	FromDate          string
-	ToDate            string
-	StreamTypes       []string
-	AuthorizedChannel string
+	SearchText         string
+	SearchMode         string // "text" (the default), "regex" or "fuzzy"
+	MatchWholeWord     bool
+	NearDistance       *int     // Words allowed between the two sides of a NEAR without /N. nil uses the default.
+	Limit              int      // Transcripts per page. 0 uses the default.
+	Cursor             string   // NextCursor of the previous page, empty for the first page
+	Sort               string   // Order of the transcripts, one of the SearchSort values. Empty sorts by newest.
+	ContextLimit       int      // Contexts per transcript. 0 uses the default.
+	ContextOffset      int      // Contexts of each transcript to skip. With a single ID, pages through the contexts of that transcript alone.
+	Snippet            bool     // Trim contexts to the words around their first match
+	Facets             []string // Facets to count the results by, of the SearchFacet values
+	Before             int      // Lines to return before each context
+	After              int      // Lines to return after each context
+	IDs                []string // Streams with any of the IDs
+	Streamers          []string // Streams by any of the streamers
+	ExcludeStreamers   []string
+	StreamTitles       []string // Streams with a title containing any of the values, ignoring case
+	ExcludeTitles      []string // Streams with a title containing none of the values, ignoring case
+	FromDate           string
+	ToDate             string
+	StreamTypes        []string
+	ExcludeStreamTypes []string
+	Speakers           []string // Matched against the speaker of each line, ignoring case
+	Langs              []string // Tracks to search, with untaggedLang for the track without a language. The default track of each transcript is searched when empty.
+	FromOffset         *int64   // Milliseconds from the start of the stream. Only lines starting at or after it are searched.
+	ToOffset           *int64   // Milliseconds from the start of the stream. Only lines starting before the end of that second are searched.
+	AuthorizedChannel  string
 }
 
 type ContextKey string